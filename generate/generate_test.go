@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -26,3 +28,74 @@ func Test(t *testing.T) {
 		t.Errorf("expected:\n%s\nbut got:\n%s\n", expected, actual)
 	}
 }
+
+const expectedErrnos = `package syscalls
+
+func init() {
+	errnoNames[2] = "ENOENT"
+	errnoNames[11] = "EAGAIN"
+	errnoAliases["EWOULDBLOCK"] = 11
+}
+`
+
+func TestErrnos(t *testing.T) {
+	var buf bytes.Buffer
+	errnoNames := map[int]string{
+		11: "EAGAIN",
+		2:  "ENOENT",
+	}
+
+	writeErrnos(&buf, errnoNames, map[string]int{"EWOULDBLOCK": 11})
+
+	if actual := buf.String(); actual != expectedErrnos {
+		t.Errorf("expected:\n%s\nbut got:\n%s\n", expectedErrnos, actual)
+	}
+}
+
+const expectedFlags = `package syscalls
+
+func init() {
+	flagValues["O_CLOEXEC"] = 0x80000
+	flagValues["O_RDONLY"] = 0x0
+}
+`
+
+func TestFlags(t *testing.T) {
+	var buf bytes.Buffer
+	flagValues := map[string]int{
+		"O_RDONLY":  0,
+		"O_CLOEXEC": 0x80000,
+	}
+
+	writeFlags(&buf, flagValues)
+
+	if actual := buf.String(); actual != expectedFlags {
+		t.Errorf("expected:\n%s\nbut got:\n%s\n", expectedFlags, actual)
+	}
+}
+
+const expectedClasses = `package syscalls
+
+func init() {
+	syscallClasses["desc"] = []string{"OPEN", "READ"}
+	syscallClasses["file"] = []string{"OPEN"}
+}
+`
+
+func TestClasses(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "classes.txt")
+	data := "# comment\n\nread desc\nopen file desc\n"
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	classes, err := readClasses(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writeClasses(&buf, classes)
+
+	if actual := buf.String(); actual != expectedClasses {
+		t.Errorf("expected:\n%s\nbut got:\n%s\n", expectedClasses, actual)
+	}
+}
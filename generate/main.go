@@ -16,13 +16,36 @@ import (
 )
 
 func main() {
-	syscallNames := getSyscalls()
-	filename := "generated.go"
+	scope := syscallScope()
+	create("generated.go", func(file io.Writer) {
+		write(file, getSyscalls(scope))
+	})
+	create("generated_errno.go", func(file io.Writer) {
+		names, aliases := getErrnos(scope)
+		writeErrnos(file, names, aliases)
+	})
+	create("generated_flags.go", func(file io.Writer) {
+		writeFlags(file, getFlags(scope))
+	})
+	classes, err := readClasses("classes.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	create("generated_classes.go", func(file io.Writer) {
+		writeClasses(file, classes)
+	})
+}
+
+// flagPrefixes are the flag and enum constants decoded by syscalls
+var flagPrefixes = []string{"AF_", "CLONE_", "MAP_", "MSG_", "O_", "PROT_", "RLIMIT_"}
+
+func create(filename string, write func(io.Writer)) {
 	file, err := os.Create(filename)
 	if err != nil {
 		log.Fatal(fmt.Errorf(`creating file "%s": %w`, filename, err))
 	}
-	write(file, syscallNames)
+	defer file.Close()
+	write(file)
 }
 
 func write(writer io.Writer, syscallNames map[int]string) {
@@ -41,7 +64,96 @@ func write(writer io.Writer, syscallNames map[int]string) {
 	fmt.Fprintf(writer, "}\n")
 }
 
-func getSyscalls() map[int]string {
+func writeErrnos(writer io.Writer, errnoNames map[int]string, errnoAliases map[string]int) {
+	fmt.Fprintf(writer, "package syscalls\n\nfunc init() {\n")
+
+	keys := make([]int, 0, len(errnoNames))
+	for key := range errnoNames {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, num := range keys {
+		name := errnoNames[num]
+		fmt.Fprintf(writer, "\terrnoNames[%d] = \"%s\"\n", num, name)
+	}
+	aliases := make([]string, 0, len(errnoAliases))
+	for alias := range errnoAliases {
+		aliases = append(aliases, alias)
+	}
+	slices.Sort(aliases)
+	for _, alias := range aliases {
+		fmt.Fprintf(writer, "\terrnoAliases[\"%s\"] = %d\n", alias, errnoAliases[alias])
+	}
+	fmt.Fprintf(writer, "}\n")
+}
+
+func writeFlags(writer io.Writer, flagValues map[string]int) {
+	fmt.Fprintf(writer, "package syscalls\n\nfunc init() {\n")
+
+	keys := make([]string, 0, len(flagValues))
+	for key := range flagValues {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, name := range keys {
+		value := flagValues[name]
+		fmt.Fprintf(writer, "\tflagValues[\"%s\"] = %#x\n", name, value)
+	}
+	fmt.Fprintf(writer, "}\n")
+}
+
+func writeClasses(writer io.Writer, classes map[string][]string) {
+	fmt.Fprintf(writer, "package syscalls\n\nfunc init() {\n")
+
+	keys := make([]string, 0, len(classes))
+	for key := range classes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, class := range keys {
+		fmt.Fprintf(writer, "\tsyscallClasses[\"%s\"] = []string{", class)
+		for i, name := range classes[class] {
+			if i > 0 {
+				fmt.Fprintf(writer, ", ")
+			}
+			fmt.Fprintf(writer, "\"%s\"", name)
+		}
+		fmt.Fprintf(writer, "}\n")
+	}
+	fmt.Fprintf(writer, "}\n")
+}
+
+// readClasses reads the syscall names of each class from lines of a
+// syscall and its classes, in the case of syscallNames
+func readClasses(filename string) (map[string][]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	classes := map[string][]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("%s:%d: syscall %s without a class", filename, i+1, fields[0])
+		}
+		for _, class := range fields[1:] {
+			classes[class] = append(classes[class], strings.ToUpper(fields[0]))
+		}
+	}
+	for _, names := range classes {
+		slices.Sort(names)
+	}
+	return classes, nil
+}
+
+// syscallScope returns the declarations of the "syscall" package
+func syscallScope() *types.Scope {
 	src := `package main
 		import "syscall"
 		
@@ -56,7 +168,10 @@ func getSyscalls() map[int]string {
 	if err != nil {
 		log.Fatal(err) // type error
 	}
-	scope := pkg.Imports()[0].Scope() // "syscall" import
+	return pkg.Imports()[0].Scope() // "syscall" import
+}
+
+func getSyscalls(scope *types.Scope) map[int]string {
 	var syscallNames = map[int]string{}
 	for _, name := range scope.Names() {
 		if !strings.HasPrefix(name, "SYS_") {
@@ -76,3 +191,64 @@ func getSyscalls() map[int]string {
 	}
 	return syscallNames
 }
+
+// getErrnos returns the E* constants of type Errno. Of aliases like
+// EAGAIN and EWOULDBLOCK, the first in alphabetical order is kept.
+// straceErrnos are the names strace prints for numbers with several
+var straceErrnos = []string{"EAGAIN", "EDEADLK", "EOPNOTSUPP"}
+
+// getErrnos returns the name of each error number, that of strace or else
+// the first alphabetically, and the other names as aliases
+func getErrnos(scope *types.Scope) (map[int]string, map[string]int) {
+	var errnoNames = map[int]string{}
+	var errnoAliases = map[string]int{}
+	for _, name := range scope.Names() { // sorted
+		if !strings.HasPrefix(name, "E") {
+			continue
+		}
+		obj := scope.Lookup(name)
+		if c, ok := obj.(*types.Const); ok && c.Type().String() == "syscall.Errno" {
+			str := c.Val().String()
+			i, err := strconv.Atoi(str)
+			if err != nil {
+				log.Printf("WARNING: %v", fmt.Errorf(
+					`converting "%s" (%s) to int: %w`, str, name, err))
+				continue
+			}
+			if other, ok := errnoNames[i]; !ok {
+				errnoNames[i] = name
+			} else if slices.Contains(straceErrnos, name) {
+				errnoNames[i] = name
+				errnoAliases[other] = i
+			} else {
+				errnoAliases[name] = i
+			}
+		}
+	}
+	return errnoNames, errnoAliases
+}
+
+// getFlags returns the constants with one of flagPrefixes, of the
+// architecture the generator runs on
+func getFlags(scope *types.Scope) map[string]int {
+	var flagValues = map[string]int{}
+	for _, name := range scope.Names() {
+		if !slices.ContainsFunc(flagPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		}) {
+			continue
+		}
+		obj := scope.Lookup(name)
+		if c, ok := obj.(*types.Const); ok {
+			str := c.Val().String()
+			i, err := strconv.Atoi(str)
+			if err != nil {
+				log.Printf("WARNING: %v", fmt.Errorf(
+					`converting "%s" (%s) to int: %w`, str, name, err))
+				continue
+			}
+			flagValues[name] = i
+		}
+	}
+	return flagValues
+}
@@ -1,9 +0,0 @@
-//go:build amd64
-
-package interceptor
-
-import "syscall"
-
-func init() {
-	syscall_OPEN = syscall.SYS_OPEN
-}
@@ -0,0 +1,231 @@
+package interceptor
+
+import (
+	"fmt"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// decoder formats syscall arguments, reading the tracee's memory
+type decoder struct {
+	provider    Provider
+	stringLimit int  // bytes of strings and elements of arrays to print
+	cQuotes     bool // quote like strace, with octal escapes
+	noFdPaths   bool // print fds without their paths
+}
+
+// formatArg formats argument i, which may need the argument after it.
+// Arguments written by the kernel are formatted at exit, with retVal.
+// Pointers to memory that cannot be read are printed in hex, like strace
+// does, as they are the tracee's mistake rather than ours.
+func (d decoder) formatArg(kind syscalls.ArgKind, args []int, i int, retVal int) string {
+	str, err := d.decodeArg(kind, args, i, retVal)
+	if err != nil {
+		return formatValue(syscalls.Hex, args[i])
+	}
+	return str
+}
+
+func (d decoder) decodeArg(kind syscalls.ArgKind, args []int, i int, retVal int) (string, error) {
+	arg := args[i]
+	if kind == syscalls.TimespecRem && arg != 0 && syscalls.IsInterrupted(retVal) {
+		// the time left, only written when interrupted
+		return d.formatStruct(kind, arg)
+	}
+	if kind.Out() && (syscalls.IsError(retVal) || arg == 0 || kind == syscalls.TimespecRem) {
+		return formatValue(syscalls.Hex, arg), nil
+	}
+	switch kind {
+	case syscalls.Fd, syscalls.Dirfd:
+		fd := int(int32(arg))
+		if kind == syscalls.Dirfd && fd == syscalls.AT_FDCWD {
+			return "AT_FDCWD", nil
+		}
+		return d.formatFd(fd), nil
+	case syscalls.Path:
+		if arg == 0 {
+			return "NULL", nil
+		}
+		path, err := d.provider.ReadPtraceText(uintptr(arg))
+		if err != nil {
+			return "", fmt.Errorf("reading path: %w", err)
+		}
+		return d.quote(path), nil
+	case syscalls.Str:
+		if arg == 0 {
+			return "NULL", nil
+		}
+		str, err := d.provider.ReadPtraceText(uintptr(arg))
+		if err != nil {
+			return "", fmt.Errorf("reading string: %w", err)
+		}
+		return d.shortString(str), nil
+	case syscalls.BufIn:
+		// ssize_t write(int fd, const void *buf, size_t count)
+		return d.readBuf(arg, args[i+1])
+	case syscalls.BufOut:
+		// ssize_t read(int fd, void *buf, size_t count)
+		if retVal > args[i+1] {
+			return formatValue(syscalls.Hex, arg), nil
+		}
+		return d.readBuf(arg, retVal)
+	case syscalls.Stat, syscalls.Timespec, syscalls.TimespecOut, syscalls.Rlimit, syscalls.RlimitOut,
+		syscalls.Sigset, syscalls.SigsetOut, syscalls.Sigaction, syscalls.SigactionOut:
+		return d.formatStruct(kind, arg)
+	case syscalls.Iovec:
+		return d.formatIovecs(arg, args[i+1], -1)
+	case syscalls.IovecOut:
+		return d.formatIovecs(arg, args[i+1], retVal)
+	case syscalls.Sockaddr:
+		return d.formatSockaddr(arg, args[i+1])
+	case syscalls.Pollfd:
+		return d.formatPollfds(arg, args[i+1], false)
+	case syscalls.PipeFds:
+		// int pipe(int pipefd[2])
+		var fds [2]int32
+		if err := d.read(arg, &fds); err != nil {
+			return "", fmt.Errorf("reading pipe fds: %w", err)
+		}
+		return fmt.Sprintf("[%s, %s]", d.formatFd(int(fds[0])), d.formatFd(int(fds[1]))), nil
+	case syscalls.StrArray:
+		return d.formatStrArray(arg)
+	case syscalls.Environ:
+		return d.formatEnviron(arg)
+	}
+	return formatValue(kind, arg), nil
+}
+
+// formatReturn formats the return value, or the error, followed by what
+// it tells about the arguments
+func (d decoder) formatReturn(sig syscalls.Signature, args []int, retVal int) string {
+	if syscalls.IsError(retVal) {
+		return syscalls.FormatError(retVal)
+	}
+	str := formatValue(sig.Ret, retVal)
+	for i, kind := range sig.Args {
+		if kind == syscalls.Pollfd && retVal > 0 {
+			// int poll(struct pollfd *fds, nfds_t nfds, int timeout)
+			if fds, err := d.formatPollfds(args[i], args[i+1], true); err == nil {
+				str += " (" + fds + ")"
+			}
+		}
+	}
+	return str
+}
+
+// formatValue formats what can be shown without reading the tracee's memory
+func formatValue(kind syscalls.ArgKind, value int) string {
+	switch kind {
+	case syscalls.Int, syscalls.Fd, syscalls.Dirfd:
+		return strconv.Itoa(int(int32(value)))
+	case syscalls.Long:
+		return strconv.Itoa(value)
+	case syscalls.Size:
+		return strconv.FormatUint(uint64(value), 10)
+	case syscalls.Mode:
+		return fmt.Sprintf("%#o", value)
+	case syscalls.Flags, syscalls.OpenFlags, syscalls.FdFlags, syscalls.AtFlags, syscalls.ProtFlags,
+		syscalls.MapFlags, syscalls.MsgFlags, syscalls.Whence, syscalls.Family, syscalls.AccessMode,
+		syscalls.Resource, syscalls.ClockId, syscalls.TimerFlags, syscalls.SigHow:
+		return syscalls.FormatFlags(kind, value)
+	case syscalls.Signal:
+		return signalName(syscall.Signal(int32(value)))
+	case syscalls.CloneFlags:
+		// the low byte is the signal the parent gets when the child exits
+		str := syscalls.FormatFlags(kind, value&^0xff)
+		if sig := syscall.Signal(value & 0xff); sig != 0 && str == "0" {
+			return signalName(sig)
+		} else if sig != 0 {
+			return str + "|" + signalName(sig)
+		}
+		return str
+	}
+	if value == 0 {
+		return "NULL"
+	}
+	return fmt.Sprintf("%#x", uint64(value))
+}
+
+// readBuf reads and quotes a buffer of size bytes, cut at the string limit
+func (d decoder) readBuf(addr, size int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	n := min(size, d.stringLimit)
+	buf, err := d.provider.ReadPtraceTextBuf(uintptr(addr), n)
+	if err != nil {
+		return "", fmt.Errorf("reading buffer: %w", err)
+	}
+	if n < size {
+		return d.quote(buf) + "...", nil
+	}
+	return d.quote(buf), nil
+}
+
+// formatFd formats an fd, with its path unless left out
+func (d decoder) formatFd(fd int) string {
+	if d.noFdPaths {
+		return strconv.Itoa(fd)
+	}
+	return formatFileDesc(fd, d.provider.FileName(fd))
+}
+
+func formatFileDesc(fd int, path string) string {
+	if path != "" {
+		return fmt.Sprintf(`%d<%s>`, fd, path)
+	} else {
+		return strconv.Itoa(fd)
+	}
+}
+
+// shortString quotes the string, cut at the string limit
+func (d decoder) shortString(str string) string {
+	if limit := d.stringLimit; len(str) > limit {
+		return d.quote(str[:limit]) + "..."
+	}
+	return d.quote(str)
+}
+
+// quote quotes a string like Go, or like strace
+func (d decoder) quote(str string) string {
+	if d.cQuotes {
+		return quoteC(str)
+	}
+	return strconv.Quote(str)
+}
+
+// quoteC quotes a string like strace does, escaping what is not printable
+// ASCII in octal, with three digits only where an octal digit follows
+func quoteC(str string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			if ' ' <= c && c <= '~' {
+				b.WriteByte(c)
+			} else if i+1 < len(str) && '0' <= str[i+1] && str[i+1] <= '7' {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				fmt.Fprintf(&b, "\\%o", c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
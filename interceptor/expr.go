@@ -0,0 +1,240 @@
+package interceptor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+)
+
+// Expr selects syscalls with qualifying expressions like those of strace:
+//
+//	trace=open,close    by name
+//	trace=%file         by class: %file %desc %network %process %memory %signal %ipc
+//	trace=/^l?stat      by regular expression
+//	trace=!%memory      all but the set, also with all and none
+//	trace=?pidfd_open   ignoring names unknown on this architecture
+//	status=failed       by result: successful or failed
+//	trace-fds=0,1,2     with an fd argument in the set
+//
+// and the syscalls with a path or fd of any of Paths. The zero Expr
+// selects all syscalls. Faults to inject are given with inject=, see
+// Fault, and do not select syscalls.
+type Expr struct {
+	Syscalls map[int]bool // nil for all
+	Status   *Status      // nil for any
+	Fds      *FdSet       // nil for any
+	Paths    []string     // absolute, nil for any
+	Faults   []Fault
+}
+
+// Status selects syscalls by their result
+type Status struct {
+	Successful, Failed bool
+}
+
+// FdSet is a set of fds, or its complement
+type FdSet struct {
+	Fds     map[int]bool
+	Negated bool
+}
+
+// SelectsAll tells whether the Expr selects all syscalls
+func (e *Expr) SelectsAll() bool {
+	return e.Syscalls == nil && e.Status == nil && e.Fds == nil && e.Paths == nil
+}
+
+func (e *Expr) String() string {
+	return fmt.Sprint(*e)
+}
+
+// Set adds a qualifying expression, as given with -e
+func (e *Expr) Set(value string) error {
+	qualifier, set, found := strings.Cut(value, "=")
+	if !found {
+		qualifier, set = "trace", value
+	}
+	set, negated := strings.CutPrefix(set, "!")
+	if set == "" {
+		return fmt.Errorf(`empty set in "%s"`, value)
+	}
+	switch qualifier {
+	case "trace", "t":
+		nums, err := parseSyscalls(set)
+		if err != nil {
+			return err
+		}
+		if e.Syscalls == nil {
+			e.Syscalls = map[int]bool{}
+		}
+		for _, num := range syscalls.Nums() {
+			if nums[num] != negated {
+				e.Syscalls[num] = true
+			}
+		}
+	case "status":
+		status, err := parseStatus(set)
+		if err != nil {
+			return err
+		}
+		if negated {
+			status = Status{!status.Successful, !status.Failed}
+		}
+		e.Status = &status
+	case "inject":
+		fault, err := parseFault(set, negated)
+		if err != nil {
+			return err
+		}
+		e.Faults = append(e.Faults, fault)
+	case "trace-fds":
+		fds := FdSet{map[int]bool{}, negated}
+		for _, str := range strings.Split(set, ",") {
+			fd, err := strconv.Atoi(str)
+			if err != nil || fd < 0 {
+				return fmt.Errorf(`invalid fd "%s"`, str)
+			}
+			fds.Fds[fd] = true
+		}
+		e.Fds = &fds
+	default:
+		return fmt.Errorf(`unsupported qualifier "%s"`, qualifier)
+	}
+	return nil
+}
+
+// AddPath selects the syscalls with a path or fd of the file, as given
+// with -P
+func (e *Expr) AddPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	e.Paths = append(e.Paths, abs)
+	if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+		e.Paths = append(e.Paths, real) // as the tracer reports paths of fds
+	}
+	return nil
+}
+
+func parseSyscalls(set string) (map[int]bool, error) {
+	nums := map[int]bool{}
+	for _, elem := range strings.Split(set, ",") {
+		if class, ok := strings.CutPrefix(elem, "%"); ok {
+			classNums, ok := syscalls.GetClass(class)
+			if !ok {
+				return nil, fmt.Errorf(`invalid class "%s"`, elem)
+			}
+			for _, num := range classNums {
+				nums[num] = true
+			}
+			continue
+		}
+		if pattern, ok := strings.CutPrefix(elem, "/"); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid regular expression "%s": %w`, pattern, err)
+			}
+			for _, num := range syscalls.Nums() {
+				if re.MatchString(strings.ToLower(syscalls.GetName(num))) {
+					nums[num] = true
+				}
+			}
+			continue
+		}
+		switch elem {
+		case "all":
+			for _, num := range syscalls.Nums() {
+				nums[num] = true
+			}
+			continue
+		case "none":
+			continue
+		}
+		name, optional := strings.CutPrefix(elem, "?")
+		num, ok := syscalls.GetNum(name)
+		if !ok && !optional {
+			return nil, fmt.Errorf(`invalid system call "%s"`, name)
+		} else if ok {
+			nums[num] = true
+		}
+	}
+	return nums, nil
+}
+
+func parseStatus(set string) (Status, error) {
+	var status Status
+	for _, elem := range strings.Split(set, ",") {
+		switch elem {
+		case "successful":
+			status.Successful = true
+		case "failed":
+			status.Failed = true
+		case "all":
+			status = Status{true, true}
+		case "none":
+		default:
+			return status, fmt.Errorf(`unsupported status "%s"`, elem)
+		}
+	}
+	return status, nil
+}
+
+// matchEntry tells whether a syscall is selected by all but its result
+func (e *Expr) matchEntry(provider Provider, syscallNum int, args []int) bool {
+	if e.Syscalls != nil && !e.Syscalls[syscallNum] {
+		return false
+	}
+	if e.Fds == nil && e.Paths == nil {
+		return true
+	}
+	sig := syscalls.GetSignature(syscallNum)
+	fdMatch, pathMatch := e.Fds == nil, e.Paths == nil
+	for i, kind := range sig.Args {
+		switch kind {
+		case syscalls.Fd, syscalls.Dirfd:
+			fd := int(int32(args[i]))
+			if kind == syscalls.Dirfd && fd == syscalls.AT_FDCWD {
+				continue
+			}
+			fdMatch = fdMatch || fd >= 0 && e.Fds.Fds[fd] != e.Fds.Negated
+			pathMatch = pathMatch || e.hasPath(provider.FileName(fd))
+		case syscalls.Path:
+			if pathMatch || args[i] == 0 {
+				continue
+			}
+			path, err := provider.ReadPtraceText(uintptr(args[i]))
+			if err != nil {
+				continue
+			}
+			dirfd := syscalls.AT_FDCWD
+			if i > 0 && sig.Args[i-1] == syscalls.Dirfd {
+				dirfd = int(int32(args[i-1]))
+			}
+			pathMatch = e.hasPath(provider.ResolvePath(dirfd, path))
+		}
+	}
+	return fdMatch && pathMatch
+}
+
+func (e *Expr) hasPath(path string) bool {
+	for _, p := range e.Paths {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExit tells whether a syscall is selected by its result
+func (e *Expr) matchExit(retVal int) bool {
+	if e.Status == nil {
+		return true
+	}
+	if syscalls.IsError(retVal) {
+		return e.Status.Failed
+	}
+	return e.Status.Successful
+}
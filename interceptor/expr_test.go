@@ -0,0 +1,57 @@
+package interceptor
+
+import (
+	"strace/syscalls"
+	"syscall"
+	"testing"
+)
+
+func TestExprSyscalls(t *testing.T) {
+	for _, test := range []struct {
+		exprs    []string
+		selected []int
+		left     []int
+	}{
+		{[]string{"read,write"}, []int{syscall.SYS_READ, syscall.SYS_WRITE}, []int{syscall.SYS_CLOSE}},
+		{[]string{"trace=%memory"}, []int{syscall.SYS_MMAP, syscall.SYS_BRK}, []int{syscall.SYS_READ}},
+		{[]string{"trace=!%memory"}, []int{syscall.SYS_READ}, []int{syscall.SYS_MMAP}},
+		{[]string{"trace=/^m(un)?map$"}, []int{syscall.SYS_MMAP, syscall.SYS_MUNMAP}, []int{syscall.SYS_MREMAP}},
+		{[]string{"trace=?no_such_call,close", "trace=%network"}, []int{syscall.SYS_CLOSE, syscall.SYS_SOCKET}, []int{syscall.SYS_READ}},
+	} {
+		var expr Expr
+		for _, str := range test.exprs {
+			if err := expr.Set(str); err != nil {
+				t.Fatalf("%v: %v", test.exprs, err)
+			}
+		}
+		for _, num := range test.selected {
+			if !expr.Syscalls[num] {
+				t.Errorf("%v: expected %s to be selected", test.exprs, syscalls.GetName(num))
+			}
+		}
+		for _, num := range test.left {
+			if expr.Syscalls[num] {
+				t.Errorf("%v: expected %s not to be selected", test.exprs, syscalls.GetName(num))
+			}
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, str := range []string{"no_such_call", "trace=%nothing", "trace=/(", "status=odd", "trace-fds=x", "read=0", "trace="} {
+		var expr Expr
+		if err := expr.Set(str); err == nil {
+			t.Errorf("%s: expected an error", str)
+		}
+	}
+}
+
+func TestExprStatus(t *testing.T) {
+	var expr Expr
+	if err := expr.Set("status=!failed"); err != nil {
+		t.Fatal(err)
+	}
+	if !expr.matchExit(0) || expr.matchExit(-int(syscall.ENOENT)) {
+		t.Errorf("expected only successful syscalls to be selected")
+	}
+}
@@ -0,0 +1,64 @@
+package interceptor
+
+import (
+	"strace/syscalls"
+	"syscall"
+)
+
+// Filter passes only the syscalls selected by expr on to the interceptor.
+// When expr selects by result, syscalls are passed on at their exit, with
+// Before right before After, so interceptors timing syscalls should take
+// the start from Provider.EntryTime.
+func Filter(provider Provider, expr Expr, inter Interceptor) Interceptor {
+	return &filter{provider, expr, inter, map[int]bool{}}
+}
+
+type filter struct {
+	provider Provider
+	expr     Expr
+	inter    Interceptor
+	entered  map[int]bool // tids making a selected syscall
+}
+
+func (f *filter) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	tid := f.provider.Tid()
+	if !f.expr.matchEntry(f.provider, syscallNum, []int{arg1, arg2, arg3, arg4, arg5, arg6}) {
+		delete(f.entered, tid)
+		return nil
+	}
+	f.entered[tid] = true
+	if f.expr.Status != nil {
+		return nil // at exit
+	}
+	return f.inter.Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6)
+}
+
+func (f *filter) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := f.provider.Tid()
+	if !f.entered[tid] {
+		return nil
+	}
+	delete(f.entered, tid)
+	if f.expr.Status != nil {
+		if !f.expr.matchExit(retVal) {
+			return nil
+		}
+		if err := f.inter.Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6); err != nil {
+			return err
+		}
+	}
+	return f.inter.After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal)
+}
+
+func (f *filter) Signal(tid int, info syscalls.Siginfo) {
+	if observer, ok := f.inter.(Observer); ok {
+		observer.Signal(tid, info)
+	}
+}
+
+func (f *filter) Exit(tid int, status syscall.WaitStatus) {
+	delete(f.entered, tid)
+	if observer, ok := f.inter.(Observer); ok {
+		observer.Exit(tid, status)
+	}
+}
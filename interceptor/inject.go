@@ -0,0 +1,270 @@
+package interceptor
+
+import (
+	"fmt"
+	"math/rand"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Trigger selects the calls of syscalls to act on, given by a set of
+// syscalls like with trace=, and
+//
+//	when=3              the 3rd call only
+//	when=3+             the 3rd call and later
+//	when=3+2            every 2nd call from the 3rd
+//	when=3..5           the 3rd to the 5th call
+//	probability=0.1     a random tenth of the calls
+//	path=file           calls with a path or fd of the file
+//	fd=3                calls with the fd
+//
+// Calls are counted from 1 for each Trigger, over all traced threads.
+type Trigger struct {
+	Expr                // selects by syscall, path and fd
+	First, Last int     // occurrences, with Last 0 for no last
+	Step        int     // between occurrences
+	Probability float64 // 0 for always
+	calls       int     // selected so far
+}
+
+func newTrigger(set string, negated bool) (Trigger, error) {
+	nums, err := parseSyscalls(set)
+	if err != nil {
+		return Trigger{}, err
+	}
+	trigger := Trigger{Expr: Expr{Syscalls: map[int]bool{}}, First: 1, Step: 1}
+	for _, num := range syscalls.Nums() {
+		if nums[num] != negated {
+			trigger.Syscalls[num] = true
+		}
+	}
+	return trigger, nil
+}
+
+// parse parses key=value, telling whether the key is that of a Trigger
+func (t *Trigger) parse(key, value string) (bool, error) {
+	switch key {
+	case "when":
+		return true, t.parseWhen(value)
+	case "probability":
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p <= 0 || p > 1 {
+			return true, fmt.Errorf(`invalid probability "%s"`, value)
+		}
+		t.Probability = p
+	case "path":
+		return true, t.AddPath(value)
+	case "fd":
+		fd, err := strconv.Atoi(value)
+		if err != nil || fd < 0 {
+			return true, fmt.Errorf(`invalid fd "%s"`, value)
+		}
+		if t.Fds == nil {
+			t.Fds = &FdSet{Fds: map[int]bool{}}
+		}
+		t.Fds.Fds[fd] = true
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// parseWhen parses first[..last][+[step]]
+func (t *Trigger) parseWhen(when string) error {
+	invalid := fmt.Errorf(`invalid when "%s"`, when)
+	first, step, hasStep := strings.Cut(when, "+")
+	first, last, hasLast := strings.Cut(first, "..")
+	var err error
+	if t.First, err = strconv.Atoi(first); err != nil || t.First < 1 {
+		return invalid
+	}
+	t.Last = t.First
+	if hasStep {
+		t.Last = 0
+	}
+	if hasLast {
+		if t.Last, err = strconv.Atoi(last); err != nil || t.Last < t.First {
+			return invalid
+		}
+	}
+	if step != "" {
+		if t.Step, err = strconv.Atoi(step); err != nil || t.Step < 1 {
+			return invalid
+		}
+	}
+	return nil
+}
+
+// occurs tells whether the trigger fires at the nth call
+func (t *Trigger) occurs(n int) bool {
+	return n >= t.First && (t.Last == 0 || n <= t.Last) && (n-t.First)%t.Step == 0
+}
+
+// fire counts a syscall at entry, and tells whether the trigger fires
+func (t *Trigger) fire(provider Provider, syscallNum int, args []int, rand *rand.Rand) bool {
+	if !t.matchEntry(provider, syscallNum, args) {
+		return false
+	}
+	t.calls++
+	if !t.occurs(t.calls) {
+		return false
+	}
+	return t.Probability == 0 || rand.Float64() < t.Probability
+}
+
+// Fault makes the syscalls its Trigger selects return RetVal without
+// making them, or makes them slow, as given with
+//
+//	inject=set:error=ENOSPC        fail with an errno, by name or number
+//	inject=set:retval=0            or succeed with a value
+//	inject=set:delay_enter=10ms    hold the thread before the syscall
+//	inject=set:delay_exit=5ms..1s  or after it, for a uniformly random time
+//	inject=set:...:when=3+         and the other keys of Trigger
+type Fault struct {
+	Trigger
+	Return                bool  // whether to skip the syscall, returning RetVal
+	RetVal                int   // -errno for an error
+	DelayEnter, DelayExit Delay // zero for none
+}
+
+func parseFault(set string, negated bool) (Fault, error) {
+	elems := strings.Split(set, ":")
+	trigger, err := newTrigger(elems[0], negated)
+	if err != nil {
+		return Fault{}, err
+	}
+	fault := Fault{Trigger: trigger}
+	for _, elem := range elems[1:] {
+		key, value, _ := strings.Cut(elem, "=")
+		if ok, err := fault.parse(key, value); ok {
+			if err != nil {
+				return fault, err
+			}
+			continue
+		}
+		switch key {
+		case "error":
+			errno, err := parseErrno(value)
+			if err != nil {
+				return fault, err
+			}
+			fault.RetVal, fault.Return = -errno, true
+		case "retval":
+			n, err := strconv.Atoi(value)
+			if err != nil || syscalls.IsError(n) {
+				return fault, fmt.Errorf(`invalid retval "%s"`, value)
+			}
+			fault.RetVal, fault.Return = n, true
+		case "delay_enter", "delay_exit":
+			delay, err := parseDelay(value)
+			if err != nil {
+				return fault, err
+			}
+			if key == "delay_enter" {
+				fault.DelayEnter = delay
+			} else {
+				fault.DelayExit = delay
+			}
+		default:
+			return fault, fmt.Errorf(`unsupported inject argument "%s"`, elem)
+		}
+	}
+	if !fault.Return && fault.DelayEnter.Max == 0 && fault.DelayExit.Max == 0 {
+		return fault, fmt.Errorf(`inject needs error=, retval=, delay_enter= or delay_exit= in "%s"`, set)
+	}
+	return fault, nil
+}
+
+// parseErrno parses an error number, or its name like ENOSPC
+func parseErrno(str string) (int, error) {
+	if errno, ok := syscalls.GetErrno(str); ok {
+		return errno, nil
+	}
+	if n, err := strconv.Atoi(str); err == nil && n > 0 && n < 4096 {
+		return n, nil
+	}
+	return 0, fmt.Errorf(`invalid error "%s"`, str)
+}
+
+// Delay is a time, or a range of times to pick uniformly from
+type Delay struct {
+	Min, Max time.Duration
+}
+
+// parseDelay parses a duration like 10ms, or a range like 10ms..50ms
+func parseDelay(str string) (Delay, error) {
+	min, max, isRange := strings.Cut(str, "..")
+	var delay Delay
+	var err error
+	if delay.Min, err = time.ParseDuration(min); err != nil || delay.Min <= 0 {
+		return delay, fmt.Errorf(`invalid delay "%s"`, str)
+	}
+	delay.Max = delay.Min
+	if isRange {
+		if delay.Max, err = time.ParseDuration(max); err != nil || delay.Max < delay.Min {
+			return delay, fmt.Errorf(`invalid delay "%s"`, str)
+		}
+	}
+	return delay, nil
+}
+
+func (d Delay) pick(rand *rand.Rand) time.Duration {
+	if d.Max == d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rand.Int63n(int64(d.Max-d.Min)+1))
+}
+
+// Inject makes syscalls fail, return other values or take longer, as the
+// faults tell. Delays hold only the thread making the syscall. Probabilities
+// and delays are drawn from a source seeded with seed, so a run with the
+// same syscalls injects the same faults.
+func Inject(provider Provider, faults []Fault, seed int64) Interceptor {
+	return &injector{provider, faults, map[int]Delay{}, rand.New(rand.NewSource(seed))}
+}
+
+type injector struct {
+	provider Provider
+	faults   []Fault
+	exits    map[int]Delay // to hold threads for at the exit of their syscall
+	rand     *rand.Rand
+}
+
+func (i *injector) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	for n := range i.faults {
+		fault := &i.faults[n]
+		if !fault.fire(i.provider, syscallNum, args, i.rand) {
+			continue
+		}
+		if fault.DelayEnter.Max > 0 {
+			i.provider.Delay(fault.DelayEnter.pick(i.rand))
+		}
+		if fault.DelayExit.Max > 0 {
+			i.exits[i.provider.Tid()] = fault.DelayExit
+		}
+		if fault.Return {
+			return i.provider.SkipSyscall(fault.RetVal)
+		}
+		return nil
+	}
+	return nil
+}
+
+func (i *injector) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := i.provider.Tid()
+	if delay, ok := i.exits[tid]; ok {
+		delete(i.exits, tid)
+		i.provider.Delay(delay.pick(i.rand))
+	}
+	return nil
+}
+
+func (i *injector) Signal(tid int, info syscalls.Siginfo) {}
+
+func (i *injector) Exit(tid int, status syscall.WaitStatus) {
+	delete(i.exits, tid)
+}
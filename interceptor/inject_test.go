@@ -0,0 +1,69 @@
+package interceptor
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFaultWhen(t *testing.T) {
+	for _, test := range []struct {
+		when   string
+		occurs []int
+		left   []int
+	}{
+		{"", []int{1, 2, 3}, nil},
+		{"3", []int{3}, []int{1, 2, 4}},
+		{"3+", []int{3, 4, 100}, []int{1, 2}},
+		{"3+2", []int{3, 5, 7}, []int{1, 2, 4, 6}},
+		{"2..4", []int{2, 3, 4}, []int{1, 5}},
+		{"2..6+2", []int{2, 4, 6}, []int{1, 3, 5, 8}},
+	} {
+		str := "inject=read:error=EIO"
+		if test.when != "" {
+			str += ":when=" + test.when
+		}
+		var expr Expr
+		if err := expr.Set(str); err != nil {
+			t.Fatalf("%s: %v", str, err)
+		}
+		fault := expr.Faults[0]
+		if fault.RetVal != -int(syscall.EIO) || !fault.Syscalls[syscall.SYS_READ] {
+			t.Errorf("%s: unexpected %+v", str, fault)
+		}
+		for _, n := range test.occurs {
+			if !fault.occurs(n) {
+				t.Errorf("%s: expected call %d to fail", str, n)
+			}
+		}
+		for _, n := range test.left {
+			if fault.occurs(n) {
+				t.Errorf("%s: expected call %d not to fail", str, n)
+			}
+		}
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	var expr Expr
+	if err := expr.Set("inject=fsync:delay_enter=10ms:delay_exit=1ms..2ms"); err != nil {
+		t.Fatal(err)
+	}
+	fault := expr.Faults[0]
+	if fault.Return || fault.DelayEnter != (Delay{10 * time.Millisecond, 10 * time.Millisecond}) ||
+		fault.DelayExit != (Delay{time.Millisecond, 2 * time.Millisecond}) {
+		t.Errorf("unexpected %+v", fault)
+	}
+}
+
+func TestFaultErrors(t *testing.T) {
+	for _, str := range []string{"inject=read", "inject=read:error=ENOTHING", "inject=read:retval=-1",
+		"inject=read:error=EIO:when=0", "inject=read:error=EIO:when=3..2", "inject=read:error=EIO:probability=2",
+		"inject=read:error=EIO:fd=x", "inject=read:error=EIO:signal=SIGSEGV",
+		"inject=read:delay_enter=10", "inject=read:delay_exit=2s..1s"} {
+		var expr Expr
+		if err := expr.Set(str); err == nil {
+			t.Errorf("%s: expected an error", str)
+		}
+	}
+}
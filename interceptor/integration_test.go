@@ -0,0 +1,136 @@
+package interceptor_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strace/interceptor"
+	"strace/tracer/tracertest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	files := map[string]string{"a.txt": "remote a", "b.txt": "remote b", "broken.txt": "remote broken"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[path.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && path.Base(r.URL.Path) == "broken.txt" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir() // with no files, they are all remote
+	m, err := interceptor.ParseProxyMapping(filepath.Join(dir, "*.txt") + "=" + server.URL + "/files/{name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxied := func(cmd *exec.Cmd) string {
+		var proxy interceptor.Proxier
+		out := tracertest.RunCmd(t, cmd, func(p interceptor.Provider) interceptor.Interceptor {
+			if proxy, err = interceptor.Proxy(p, []interceptor.ProxyMapping{m}); err != nil {
+				t.Fatal(err)
+			}
+			return proxy
+		})
+		if err := proxy.Close(); err != nil {
+			t.Error(err)
+		}
+		return out
+	}
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	missing := filepath.Join(dir, "c.txt") // which cat fails to open
+	// each open reading from the start
+	if out := proxied(exec.Command("cat", a, missing, b, a)); out != "remote aremote bremote a" {
+		t.Errorf(`expected "remote aremote bremote a", but got %q`, out)
+	}
+	// a failed GET fails the read, not the trace
+	broken := filepath.Join(dir, "broken.txt")
+	if out := proxied(exec.Command("cat", a, broken, b)); out != "remote aremote b" {
+		t.Errorf(`expected "remote aremote b", but got %q`, out)
+	}
+	// seeking past the first block
+	if out := proxied(exec.Command("dd", "if="+a, "bs=4", "skip=1", "status=none")); out != "te a" {
+		t.Errorf(`expected "te a", but got %q`, out)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return interceptor.JSONLines(p, &buf, interceptor.WriterOptions{})
+	})
+	var read *interceptor.JSONRecord
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record interceptor.JSONRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Name == "read" && record.RetVal != nil && *record.RetVal == 5 {
+			read = &record
+		}
+	}
+	if read == nil {
+		t.Fatal("expected a read of 5 bytes")
+	}
+	if read.Version != interceptor.JSONVersion || read.Pid == 0 || read.Tid != read.Pid || read.Duration == nil {
+		t.Errorf("expected version, pid, tid and duration, but got %+v", read)
+	}
+	if len(read.Args) != 3 || read.Args[1].Pretty != `"hello"` {
+		t.Errorf(`expected 3 arguments with "hello", but got %+v`, read.Args)
+	}
+	if path := read.Fds[read.Args[0].Raw]; filepath.Base(path) != "hello" {
+		t.Errorf("expected the path of fd %d, but got %v", read.Args[0].Raw, read.Fds)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	var summary interceptor.Summarizer
+	tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		summary = interceptor.Summary(p, interceptor.SummaryOptions{SortBy: interceptor.SortName, PerProcess: true})
+		return summary
+	})
+	var buf bytes.Buffer
+	if err := summary.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Count(out, "% time") != 2 || !strings.Contains(out, "Process ") || !strings.Contains(out, "All processes:") {
+		t.Errorf("expected a table of the process and one of all, but got\n%s", out)
+	}
+	closeAt, readAt := strings.Index(out, " close\n"), strings.Index(out, " read\n")
+	if closeAt < 0 || readAt < 0 || closeAt > readAt {
+		t.Errorf("expected close before read, but got\n%s", out)
+	}
+}
+
+func TestPerProcess(t *testing.T) {
+	var buf bytes.Buffer
+	var done []int
+	tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return interceptor.PerProcess(p, func(pid int) (interceptor.Interceptor, error) {
+			return interceptor.Strace(p, &buf, interceptor.StraceOptions{}), nil
+		}, func(pid int) {
+			// after its interceptor has seen it exit
+			if !strings.HasSuffix(buf.String(), "+++ exited with 0 +++\n") {
+				t.Errorf("expected the exit before done, but got\n%s", buf.String())
+			}
+			done = append(done, pid)
+		})
+	})
+	if len(done) != 1 {
+		t.Errorf("expected done once for cat, but got %v", done)
+	}
+}
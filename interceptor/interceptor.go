@@ -1,15 +1,47 @@
 package interceptor
 
+import (
+	"strace/syscalls"
+	"syscall"
+	"time"
+)
+
+// Interceptor is called at every syscall entry (Before) and exit (After).
+// An error makes the tracer apply its error policy.
 type Interceptor interface {
-	Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int)
-	After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int)
+	Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error
+	After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error
+}
+
+// Observer is implemented by interceptors that are also told about the
+// signals delivered to the tracees, and their exits
+type Observer interface {
+	Signal(tid int, info syscalls.Siginfo)
+	Exit(tid int, status syscall.WaitStatus) // while still counted by Tasks
 }
 
 // Provider provides access to common functionality and data
 type Provider interface {
-	ReadPtraceText(addr uintptr) string
-	ReadPtraceTextBuf(addr uintptr, size int) string
-	FileDescriptor(filename string) int
-	FileName(fd int) string
-	PutFileDescriptor(fd int, path string)
+	ReadPtraceText(addr uintptr) (string, error)
+	ReadPtraceTextBuf(addr uintptr, size int) (string, error)
+	ReadMemory(addr uintptr, buf []byte) error
+	WriteMemory(addr uintptr, data []byte) error
+	SetSyscallNum(num int) error            // at entry, to make another syscall
+	SetArg(n, value int) error              // at entry, argument n from 1
+	SetArgString(n int, value string) error // at entry, to a string written below the stack
+	SetRetVal(value int) error              // at exit
+	SkipSyscall(retVal int) error           // at entry, not making the syscall
+	Delay(d time.Duration)                  // resume the thread d later, leaving the others running
+	FileDescriptor(filename string) int     // the lowest fd open for the file, or -1
+	FileDescriptors(filename string) []int  // the fds open for the file, relative to the cwd
+	FileName(fd int) string                 // absolute, canonical path
+	RawFileName(fd int) string              // path as opened
+	FileOffset(fd int) (int64, error)       // of the open file, as moved by the syscall at exit
+	Cwd() string
+	ResolvePath(dirfd int, path string) string // absolute path, relative to dirfd or cwd
+	Tid() int                                  // thread making the current syscall
+	Pid() int                                  // process of the thread
+	Tasks() int                                // number of threads traced
+	Time() time.Time                           // of the current syscall-stop, with a monotonic reading
+	EntryTime() time.Time                      // of the entry of the current syscall, the same at entry
 }
@@ -0,0 +1,120 @@
+package interceptor
+
+import (
+	"encoding/json"
+	"io"
+	"strace/syscalls"
+	"strings"
+	"time"
+)
+
+// JSONVersion is the version of JSONRecord. It is raised when fields are
+// removed or change their meaning, not when fields are added.
+const JSONVersion = 1
+
+// JSONRecord is a line of JSONLines output, one completed syscall
+type JSONRecord struct {
+	Version  int            `json:"version"`  // JSONVersion
+	Time     time.Time      `json:"time"`     // at entry, RFC 3339 with nanoseconds
+	Pid      int            `json:"pid"`      // process
+	Tid      int            `json:"tid"`      // thread
+	Name     string         `json:"name"`     // like "openat"
+	Number   int            `json:"number"`   // of the architecture traced
+	Args     []JSONArg      `json:"args"`     // as many as the syscall has
+	RetVal   *int           `json:"retval"`   // raw, null if the syscall does not return
+	Return   string         `json:"return"`   // formatted, "?" if the syscall does not return
+	Errno    string         `json:"errno"`    // like "ENOENT", "" on success
+	Duration *int64         `json:"duration"` // in nanoseconds, null if the syscall does not return
+	Fds      map[int]string `json:"fds"`      // paths of the fds in args and retval, by fd
+}
+
+// JSONArg is a syscall argument
+type JSONArg struct {
+	Raw    int    `json:"raw"`    // register value, as signed 64-bit integer
+	Pretty string `json:"pretty"` // formatted as by Writer
+}
+
+// JSONLines writes a JSONRecord per line to out for every syscall
+func JSONLines(provider Provider, out io.Writer, options WriterOptions) Interceptor {
+	if options.StringLimit == 0 {
+		options.StringLimit = 32
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false) // fds are printed like 3</path>
+	return &jsonLines{decoder{provider: provider, stringLimit: options.StringLimit}, provider, map[int]*JSONRecord{}, encoder}
+}
+
+type jsonLines struct {
+	decoder
+	provider Provider
+	entered  map[int]*JSONRecord // by tid
+	encoder  *json.Encoder
+}
+
+func (j *jsonLines) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	record := &JSONRecord{
+		Version: JSONVersion,
+		Time:    j.provider.EntryTime(),
+		Pid:     j.provider.Pid(),
+		Tid:     j.provider.Tid(),
+		Name:    strings.ToLower(syscalls.GetName(syscallNum)),
+		Number:  syscallNum,
+		Args:    make([]JSONArg, len(sig.Args)),
+		Fds:     map[int]string{},
+	}
+	for i, kind := range sig.Args {
+		record.Args[i].Raw = args[i]
+		if kind.Out() {
+			continue // at exit
+		}
+		str := j.formatArg(kind, args, i, 0)
+		record.Args[i].Pretty = str
+		if kind == syscalls.Fd || kind == syscalls.Dirfd {
+			j.addFd(record, int(int32(args[i])))
+		}
+	}
+
+	if sig.Ret == syscalls.NoReturn {
+		record.Return = "?"
+		return j.encoder.Encode(record)
+	}
+	j.entered[record.Tid] = record
+	return nil
+}
+
+func (j *jsonLines) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	record, ok := j.entered[j.provider.Tid()]
+	if !ok {
+		return nil // entered before attaching
+	}
+	delete(j.entered, record.Tid)
+	duration := j.provider.Time().Sub(record.Time).Nanoseconds()
+	record.Duration = &duration
+	record.RetVal = &retVal
+
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	for i, kind := range sig.Args {
+		if !kind.Out() {
+			continue
+		}
+		str := j.formatArg(kind, args, i, retVal)
+		record.Args[i].Pretty = str
+	}
+	ret := j.formatReturn(sig, args, retVal)
+	record.Return = ret
+	if syscalls.IsError(retVal) {
+		record.Errno = syscalls.GetErrnoName(-retVal)
+	} else if sig.Ret == syscalls.Fd {
+		j.addFd(record, retVal)
+	}
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonLines) addFd(record *JSONRecord, fd int) {
+	if path := j.provider.FileName(fd); path != "" {
+		record.Fds[fd] = path
+	}
+}
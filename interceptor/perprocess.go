@@ -0,0 +1,88 @@
+package interceptor
+
+import (
+	"strace/syscalls"
+	"syscall"
+)
+
+// PerProcess passes syscalls on to an interceptor of each process, made
+// by newInterceptor when the process makes its first syscall. Once the last
+// thread of the process has exited, its interceptor is dropped and done is
+// called, if not nil, to release what it holds.
+func PerProcess(provider Provider, newInterceptor func(pid int) (Interceptor, error), done func(pid int)) Interceptor {
+	return &perProcess{provider, newInterceptor, done, map[int]Interceptor{}, map[int]int{}}
+}
+
+type perProcess struct {
+	provider       Provider
+	newInterceptor func(pid int) (Interceptor, error)
+	done           func(pid int)
+	inters         map[int]Interceptor // by pid
+	pids           map[int]int         // by tid
+}
+
+func (p *perProcess) interceptor() (Interceptor, error) {
+	pid := p.provider.Pid()
+	p.pids[p.provider.Tid()] = pid
+	inter, ok := p.inters[pid]
+	if !ok {
+		var err error
+		if inter, err = p.newInterceptor(pid); err != nil {
+			return nil, err
+		}
+		p.inters[pid] = inter
+	}
+	return inter, nil
+}
+
+func (p *perProcess) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	inter, err := p.interceptor()
+	if err != nil {
+		return err
+	}
+	return inter.Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6)
+}
+
+func (p *perProcess) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	inter, err := p.interceptor()
+	if err != nil {
+		return err
+	}
+	return inter.After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal)
+}
+
+// observer returns the observer of the process of a thread seen before
+func (p *perProcess) observer(tid int) (Observer, bool) {
+	pid, ok := p.pids[tid]
+	if !ok {
+		return nil, false
+	}
+	observer, ok := p.inters[pid].(Observer)
+	return observer, ok
+}
+
+func (p *perProcess) Signal(tid int, info syscalls.Siginfo) {
+	if observer, ok := p.observer(tid); ok {
+		observer.Signal(tid, info)
+	}
+}
+
+func (p *perProcess) Exit(tid int, status syscall.WaitStatus) {
+	if observer, ok := p.observer(tid); ok {
+		observer.Exit(tid, status)
+	}
+	pid, ok := p.pids[tid]
+	if !ok {
+		return
+	}
+	delete(p.pids, tid)
+	for _, other := range p.pids {
+		if other == pid {
+			return
+		}
+	}
+	delete(p.inters, pid)
+	if p.done != nil {
+		p.done(pid)
+	}
+}
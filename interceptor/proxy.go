@@ -1,144 +1,278 @@
 package interceptor
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strace/syscalls"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 )
 
-// Proxy proxies `read` from a given file to HTTP Range requests
-func Proxy(filename, url string, provider Provider) Interceptor {
-	stderr := os.Stderr
-	p := proxy{
-		filename:   filename,
-		url:        url,
-		size:       -1,
-		httpClient: http.Client{Timeout: 5 * time.Second},
-		enabled:    filename != "" && url != "",
-		provider:   provider,
-		stderr:     stderr,
+// ProxyMapping maps the files matching a glob to URLs
+type ProxyMapping struct {
+	Pattern string // absolute, see filepath.Match
+	URL     string // with {name} and {path} replaced by those of the file
+}
+
+// ParseProxyMapping parses pattern=url, with a relative pattern resolved
+// against the working directory
+func ParseProxyMapping(value string) (ProxyMapping, error) {
+	pattern, url, found := strings.Cut(value, "=")
+	return newProxyMapping(pattern, url, found, value)
+}
+
+// ReadProxyManifest reads mappings, one a line as pattern and URL separated
+// by blanks, with # comments
+func ReadProxyManifest(r io.Reader) ([]ProxyMapping, error) {
+	var mappings []ProxyMapping
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		m, err := newProxyMapping(fields[0], strings.Join(fields[1:], " "), len(fields) == 2, text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, scanner.Err()
+}
+
+func newProxyMapping(pattern, url string, ok bool, text string) (ProxyMapping, error) {
+	if !ok || pattern == "" || url == "" {
+		return ProxyMapping{}, fmt.Errorf(`expected a pattern and a URL, got "%s"`, strings.TrimSpace(text))
 	}
-	if p.enabled {
-		p.createFile()
-		p.getSize()
+	abs, err := filepath.Abs(pattern)
+	if err != nil {
+		return ProxyMapping{}, err
 	}
-	return &p
+	if _, err := filepath.Match(abs, ""); err != nil {
+		return ProxyMapping{}, fmt.Errorf(`invalid pattern "%s": %w`, pattern, err)
+	}
+	return ProxyMapping{abs, url}, nil
+}
+
+// url returns the URL of a file, or "" if the mapping does not apply
+func (m ProxyMapping) url(path string) string {
+	if ok, _ := filepath.Match(m.Pattern, path); !ok {
+		return ""
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.NewReplacer(
+		"{name}", url.PathEscape(filepath.Base(path)),
+		"{path}", strings.Join(segments, "/"),
+	).Replace(m.URL)
+}
+
+// Proxier is an Interceptor serving files from HTTP, to close when tracing
+// has ended
+type Proxier interface {
+	Interceptor
+	io.Closer
+}
+
+// Proxy proxies reads of the files matching the mappings to HTTP Range
+// requests. A file needs not exist: on its first open or stat, a HEAD
+// request gets its size, and the tracee is given an empty file of that
+// size instead, whose data is filled in at the exit of each read. When the
+// HEAD request fails, the syscall fails with ENOENT for a file not found,
+// and EIO otherwise. A read whose data cannot be fetched fails with EIO.
+func Proxy(provider Provider, mappings []ProxyMapping) (Proxier, error) {
+	dir, err := os.MkdirTemp("", "strace-proxy-")
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real // as the tracer reports paths of fds
+	}
+	return &proxy{
+		mappings:   mappings,
+		remotes:    map[string]*remote{},
+		local:      map[string]*remote{},
+		dir:        dir,
+		httpClient: http.Client{Timeout: 5 * time.Second},
+		provider:   provider,
+		stderr:     os.Stderr,
+	}, nil
 }
 
 type proxy struct {
-	filename     string
+	mappings   []ProxyMapping
+	remotes    map[string]*remote // by path, as the tracees ask for it
+	local      map[string]*remote // by path of the file given instead
+	dir        string             // of the files given instead
+	httpClient http.Client
+	provider   Provider
+	stderr     *os.File
+}
+
+// remote is a file served from a URL
+type remote struct {
 	url          string
-	httpClient   http.Client
+	path         string // of the file given instead
+	file         *os.File
 	size         int64
-	cursor       int64
 	date         string
 	lastModified string
 	contentType  string
-	file         *os.File
-	enabled      bool
-	interceptors map[int]func()
-	provider     Provider
-	stderr       *os.File
-	isTTY        bool
-}
-
-func (p *proxy) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) {
 }
 
-func (p *proxy) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) {
-	if !p.enabled {
-		return
+func (p *proxy) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	sig := syscalls.GetSignature(syscallNum)
+	for i, kind := range sig.Args {
+		if kind != syscalls.Path || args[i] == 0 {
+			continue
+		}
+		path, err := p.provider.ReadPtraceText(uintptr(args[i]))
+		if err != nil || path == "" {
+			continue
+		}
+		dirfd := syscalls.AT_FDCWD
+		if i > 0 && sig.Args[i-1] == syscalls.Dirfd {
+			dirfd = int(int32(args[i-1]))
+		}
+		r, err := p.remote(p.provider.ResolvePath(dirfd, path))
+		if err != nil {
+			// the tracee's syscall fails, as if the file were missing or unreadable
+			_, _ = p.stderr.WriteString(fmt.Sprintf("%s\n", err))
+			errno := syscall.EIO
+			if errors.Is(err, fs.ErrNotExist) {
+				errno = syscall.ENOENT
+			}
+			return p.provider.SkipSyscall(-int(errno))
+		}
+		if r != nil {
+			if err := p.provider.SetArgString(i+1, r.path); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
+func (p *proxy) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
 	switch syscallNum {
-	case syscall.SYS_LSEEK:
-		// off_t lseek(int fildes, off_t offset, int whence)
-		if p.filename == p.provider.FileName(arg1) {
-			oldOffset := p.cursor
-			newOffset := int64(-1)
-			// If whence is SEEK_SET, the file offset shall be set to offset bytes.
-			// If whence is SEEK_CUR, the file offset shall be set to its current location plus offset.
-			// If whence is SEEK_END, the file offset shall be set to the size of the file plus offset.
-			// https://pubs.opengroup.org/onlinepubs/009696799/functions/lseek.html
-			switch offset, whence := int64(arg2), arg3; whence {
-			case 0: // SEEK_SET
-				newOffset = offset
-			case 1: // SEEK_CUR
-				newOffset = oldOffset + offset
-			case 2: // SEEK_END
-				newOffset = p.getSize() + offset
-			default:
-				panic(fmt.Sprintf("LSEEK whence/arg3 unexpected value: %d", whence))
-			}
-			p.cursor = newOffset
-		}
-	case syscall.SYS_READ:
+	case syscall.SYS_READ, syscall.SYS_PREAD64:
 		// ssize_t read(int fildes, void *buf, size_t nbyte)
-		if arg1 == p.provider.FileDescriptor(p.filename) {
-			buf, err := p.read(arg1, arg3)
-			if err != nil {
-				panic(fmt.Sprintf("read: %v", err))
-			}
-			n := len(buf)
-			if n < 1 {
-				panic(fmt.Sprintf("no data from read: %d", n))
-			}
-			if n < arg3 {
-				panic(fmt.Sprintf("got %d bytes but wanted %d", n, arg3))
-			}
-			written, err := p.file.WriteAt(buf, p.cursor)
-			if err != nil {
-				panic(fmt.Sprintf("file write: %v", err))
-			}
-			if written < n {
-				panic(fmt.Sprintf("file write %d < %d", written, n))
-			}
-			p.cursor += int64(n)
+		// ssize_t pread(int fildes, void *buf, size_t nbyte, off_t offset)
+		// The kernel has read retVal zeros from the empty file, replace them.
+		r, ok := p.local[p.provider.FileName(arg1)]
+		if !ok || retVal <= 0 {
+			return nil
+		}
+		if err := p.fill(r, syscallNum, arg1, arg2, arg4, retVal); err != nil {
+			// the tracee's read fails, as it would reading a bad disk
+			_, _ = p.stderr.WriteString(fmt.Sprintf("read: %s\n", err))
+			return p.provider.SetRetVal(-int(syscall.EIO))
 		}
 	}
+	return nil
 }
 
-func (p *proxy) getSize() int64 {
-	if p.size == -1 {
-		p.fetchSize()
+// fill replaces the n zeros read or pread at offset with the data of the
+// remote file
+func (p *proxy) fill(r *remote, syscallNum, fd, addr, offset, n int) error {
+	// The kernel keeps the offset of the open file, which read has moved
+	// past what it read, for every fd and process sharing it.
+	start := int64(offset)
+	if syscallNum == syscall.SYS_READ {
+		pos, err := p.provider.FileOffset(fd)
+		if err != nil {
+			return err
+		}
+		start = pos - int64(n)
 	}
-	return p.size
+	buf, err := p.read(r, start, n)
+	if err != nil {
+		return err
+	}
+	if len(buf) < n {
+		return fmt.Errorf("got %d bytes but wanted %d", len(buf), n)
+	}
+	return p.provider.WriteMemory(uintptr(addr), buf[:n])
 }
 
-func (p *proxy) fetchSize() {
-	resp, err := p.httpClient.Head(p.url)
+// Close removes the files given instead of the remote ones
+func (p *proxy) Close() error {
+	for _, r := range p.remotes {
+		_ = r.file.Close()
+	}
+	return os.RemoveAll(p.dir)
+}
+
+// remote returns the remote file at a path, fetching its size on first
+// use, or nil if no mapping applies
+func (p *proxy) remote(path string) (*remote, error) {
+	if r, ok := p.remotes[path]; ok {
+		return r, nil
+	}
+	for _, m := range p.mappings {
+		url := m.url(path)
+		if url == "" {
+			continue
+		}
+		r := &remote{url: url}
+		if err := p.fetchSize(r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// in a directory of its own, as other remote files may have the
+		// same name
+		dir, err := os.MkdirTemp(p.dir, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := r.createFile(filepath.Join(dir, filepath.Base(path))); err != nil {
+			return nil, err
+		}
+		p.remotes[path] = r
+		p.local[r.path] = r
+		return r, nil
+	}
+	return nil, nil
+}
+
+func (p *proxy) fetchSize(r *remote) error {
+	resp, err := p.httpClient.Head(r.url)
 	if err != nil {
-		panic(fmt.Sprintf("HTTP HEAD failed: %v", err))
+		return fmt.Errorf("HTTP HEAD failed: %w", err)
 	}
-	if statusCode := resp.StatusCode; statusCode != http.StatusOK {
-		panic(fmt.Sprintf("HEAD returned status code %d", statusCode))
+	defer resp.Body.Close()
+	if statusCode := resp.StatusCode; statusCode == http.StatusNotFound || statusCode == http.StatusGone {
+		return fmt.Errorf("HEAD returned status code %d: %w", statusCode, fs.ErrNotExist)
+	} else if statusCode != http.StatusOK {
+		return fmt.Errorf("HEAD returned status code %d", statusCode)
 	}
 	length := resp.Header.Get("content-length")
 	if size, err := strconv.Atoi(length); err != nil {
-		panic(fmt.Sprintf(`invalid content-length "%s": %v`, length, err))
+		return fmt.Errorf(`invalid content-length "%s": %w`, length, err)
 	} else {
-		p.size = int64(size)
+		r.size = int64(size)
 	}
 	if ranges := resp.Header.Get("accept-ranges"); !strings.Contains(ranges, "bytes") {
-		panic(fmt.Sprintf(`accept-ranges "%s" does not accept bytes`, ranges))
-	}
-	p.contentType = resp.Header.Get("content-type")
-	p.date = resp.Header.Get("date")
-	p.lastModified = resp.Header.Get("last-modified")
-
-	b := make([]byte, p.size)
-	n, err := p.file.Write(b)
-	if err != nil {
-		panic(fmt.Sprintf(`writing to new file: %v`, err))
+		return fmt.Errorf(`accept-ranges "%s" does not accept bytes`, ranges)
 	}
+	r.contentType = resp.Header.Get("content-type")
+	r.date = resp.Header.Get("date")
+	r.lastModified = resp.Header.Get("last-modified")
 
 	lines := []string{}
-	lines = append(lines, fmt.Sprintf("> HEAD %s", p.url))
+	lines = append(lines, fmt.Sprintf("> HEAD %s", r.url))
 	lines = append(lines, "")
 	lines = append(lines, fmt.Sprintf("< %s %s", resp.Proto, resp.Status))
 	for _, name := range []string{"content-type", "content-length", "accept-ranges", "last-modified", "date"} {
@@ -148,35 +282,33 @@ func (p *proxy) fetchSize() {
 	}
 	_, _ = p.stderr.WriteString(fmt.Sprintf("\n%s\n", strings.Join(lines, "\n")))
 
-	_, _ = p.stderr.WriteString(fmt.Sprintf("wrote bytes: %d\n", n))
+	_, _ = p.stderr.WriteString(fmt.Sprintf("file size: %d\n", r.size))
+	return nil
 }
 
-func (p *proxy) read(fd int, n int) ([]byte, error) {
-	if fd != p.provider.FileDescriptor(p.filename) {
-		return []byte{}, nil
-	}
-
-	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+func (p *proxy) read(r *remote, offset int64, n int) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
 	if err != nil {
-		panic(fmt.Sprintf("http.NewRequest failed: %v", err))
+		return nil, fmt.Errorf("http.NewRequest failed: %w", err)
 	}
-	if start, size := p.cursor, p.size; start > p.size {
-		panic(fmt.Sprintf("range start %d larger than size %d", start, size))
+	if size := r.size; offset > size {
+		return nil, fmt.Errorf("range start %d larger than size %d", offset, size)
 	}
-	end := p.cursor + int64(n)
-	if size := p.size; end > p.size {
-		panic(fmt.Sprintf("range end %d larger than size %d", end, size))
+	end := offset + int64(n) - 1 // inclusive
+	if size := r.size; end >= size {
+		return nil, fmt.Errorf("range end %d larger than size %d", end, size)
 	}
-	rangeHeader := fmt.Sprintf("bytes=%d-%d", p.cursor, end)
+	rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, end)
 	req.Header.Set("Range", rangeHeader)
+	start := time.Now()
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		panic(fmt.Sprintf("HTTP GET failed: %v", err))
+		return nil, fmt.Errorf("HTTP GET failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	lines := []string{}
-	lines = append(lines, fmt.Sprintf("> GET %s", p.url))
+	lines = append(lines, fmt.Sprintf("> GET %s", r.url))
 	lines = append(lines, fmt.Sprintf("> Range: %s", rangeHeader))
 	lines = append(lines, "")
 	lines = append(lines, fmt.Sprintf("< %s %s", resp.Proto, resp.Status))
@@ -188,21 +320,30 @@ func (p *proxy) read(fd int, n int) ([]byte, error) {
 	_, _ = p.stderr.WriteString(fmt.Sprintf("\n%s\n", strings.Join(lines, "\n")))
 
 	if statusCode := resp.StatusCode; statusCode >= 300 {
-		panic(fmt.Sprintf("GET returned status code %d", statusCode))
+		return nil, fmt.Errorf("GET returned status code %d", statusCode)
 	}
 
 	buf, err := io.ReadAll(resp.Body)
 	if err != nil {
-		panic(fmt.Sprintf("reading body: %v", err))
+		return nil, fmt.Errorf("reading body: %w", err)
 	}
+	// not part of the read's time at its exit, which is before
+	_, _ = p.stderr.WriteString(fmt.Sprintf("< took %s\n", time.Since(start).Round(time.Microsecond)))
 
 	return buf, nil
 }
 
-func (p *proxy) createFile() {
-	file, err := os.Create(p.filename)
+// createFile creates the empty file given instead, with the remote size so
+// that reads get the right size
+func (r *remote) createFile(path string) error {
+	file, err := os.Create(path)
 	if err != nil {
-		panic(fmt.Sprintf(`creating file "%s": %v`, p.filename, err))
+		return fmt.Errorf(`creating file "%s": %w`, path, err)
+	}
+	r.file = file
+	r.path = path
+	if err := file.Truncate(r.size); err != nil {
+		return fmt.Errorf(`truncating new file: %w`, err)
 	}
-	p.file = file
+	return nil
 }
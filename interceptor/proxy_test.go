@@ -0,0 +1,40 @@
+package interceptor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadProxyManifest(t *testing.T) {
+	mappings, err := ReadProxyManifest(strings.NewReader(`
+# objects of the bucket
+/data/*.zip   https://example.com/zips/{name}
+/mnt/s3/*/*   https://s3.example.com/{path}  # whole path
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("expected 2 mappings, but got %d", len(mappings))
+	}
+	for _, test := range []struct {
+		m        ProxyMapping
+		path     string
+		expected string
+	}{
+		{mappings[0], "/data/a b.zip", "https://example.com/zips/a%20b.zip"},
+		{mappings[0], "/data/sub/a.zip", ""},
+		{mappings[0], "/data/a.tar", ""},
+		{mappings[1], "/mnt/s3/bucket/key", "https://s3.example.com/mnt/s3/bucket/key"},
+	} {
+		if url := test.m.url(test.path); url != test.expected {
+			t.Errorf("%s: expected %q, but got %q", test.path, test.expected, url)
+		}
+	}
+
+	for _, text := range []string{"/data/*.zip", "/data/*.zip a b", "/data/[.zip https://example.com"} {
+		if _, err := ReadProxyManifest(strings.NewReader("\n" + text)); err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+			t.Errorf("%s: expected an error on line 2, but got %v", text, err)
+		}
+	}
+}
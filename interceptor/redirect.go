@@ -0,0 +1,89 @@
+package interceptor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strace/syscalls"
+	"strings"
+)
+
+// Mapping redirects a file, or a directory and all below it, to another
+type Mapping struct {
+	From, To string // absolute
+}
+
+// ParseMapping parses from=to, with relative paths resolved against the
+// working directory
+func ParseMapping(value string) (Mapping, error) {
+	from, to, found := strings.Cut(value, "=")
+	if !found || from == "" || to == "" {
+		return Mapping{}, fmt.Errorf(`expected from=to, got "%s"`, value)
+	}
+	var m Mapping
+	var err error
+	if m.From, err = filepath.Abs(from); err != nil {
+		return m, err
+	}
+	if m.To, err = filepath.Abs(to); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// redirect returns the path mapped to, or "" if the mapping does not apply
+func (m Mapping) redirect(path string) string {
+	if path == m.From {
+		return m.To
+	}
+	if rest, ok := strings.CutPrefix(path, m.From+"/"); ok {
+		return filepath.Join(m.To, rest)
+	}
+	return ""
+}
+
+// Redirect rewrites the path arguments of syscalls like open, openat,
+// stat, access and execve as the first matching mapping tells, so that
+// the tracees see other files than those they ask for
+func Redirect(provider Provider, mappings []Mapping) Interceptor {
+	return &redirector{provider, mappings}
+}
+
+type redirector struct {
+	provider Provider
+	mappings []Mapping
+}
+
+func (r *redirector) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	sig := syscalls.GetSignature(syscallNum)
+	for i, kind := range sig.Args {
+		if kind != syscalls.Path || args[i] == 0 {
+			continue
+		}
+		path, err := r.provider.ReadPtraceText(uintptr(args[i]))
+		if err != nil || path == "" {
+			// a bad address the syscall fails with, or AT_EMPTY_PATH for
+			// the file of the dirfd
+			continue
+		}
+		dirfd := syscalls.AT_FDCWD
+		if i > 0 && sig.Args[i-1] == syscalls.Dirfd {
+			dirfd = int(int32(args[i-1]))
+		}
+		abs := r.provider.ResolvePath(dirfd, path)
+		for _, m := range r.mappings {
+			if to := m.redirect(abs); to != "" {
+				// absolute, so a dirfd before it is ignored
+				if err := r.provider.SetArgString(i+1, to); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *redirector) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+package interceptor
+
+import "testing"
+
+func TestMappingRedirect(t *testing.T) {
+	m := Mapping{"/etc/app", "/tmp/fixtures/app"}
+	for path, expected := range map[string]string{
+		"/etc/app":          "/tmp/fixtures/app",
+		"/etc/app/conf.ini": "/tmp/fixtures/app/conf.ini",
+		"/etc/app/a/b":      "/tmp/fixtures/app/a/b",
+		"/etc/apple":        "",
+		"/etc":              "",
+	} {
+		if to := m.redirect(path); to != expected {
+			t.Errorf("%s: expected %q, but got %q", path, expected, to)
+		}
+	}
+	if _, err := ParseMapping("/etc/app"); err == nil {
+		t.Errorf("expected an error without =")
+	}
+}
@@ -0,0 +1,104 @@
+package interceptor
+
+import (
+	"fmt"
+	"strace/syscalls"
+	"syscall"
+)
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT:   "SIGABRT",
+	syscall.SIGALRM:   "SIGALRM",
+	syscall.SIGBUS:    "SIGBUS",
+	syscall.SIGCHLD:   "SIGCHLD",
+	syscall.SIGCONT:   "SIGCONT",
+	syscall.SIGFPE:    "SIGFPE",
+	syscall.SIGHUP:    "SIGHUP",
+	syscall.SIGILL:    "SIGILL",
+	syscall.SIGINT:    "SIGINT",
+	syscall.SIGIO:     "SIGIO",
+	syscall.SIGKILL:   "SIGKILL",
+	syscall.SIGPIPE:   "SIGPIPE",
+	syscall.SIGPROF:   "SIGPROF",
+	syscall.SIGPWR:    "SIGPWR",
+	syscall.SIGQUIT:   "SIGQUIT",
+	syscall.SIGSEGV:   "SIGSEGV",
+	syscall.SIGSTKFLT: "SIGSTKFLT",
+	syscall.SIGSTOP:   "SIGSTOP",
+	syscall.SIGSYS:    "SIGSYS",
+	syscall.SIGTERM:   "SIGTERM",
+	syscall.SIGTRAP:   "SIGTRAP",
+	syscall.SIGTSTP:   "SIGTSTP",
+	syscall.SIGTTIN:   "SIGTTIN",
+	syscall.SIGTTOU:   "SIGTTOU",
+	syscall.SIGURG:    "SIGURG",
+	syscall.SIGUSR1:   "SIGUSR1",
+	syscall.SIGUSR2:   "SIGUSR2",
+	syscall.SIGVTALRM: "SIGVTALRM",
+	syscall.SIGWINCH:  "SIGWINCH",
+	syscall.SIGXCPU:   "SIGXCPU",
+	syscall.SIGXFSZ:   "SIGXFSZ",
+}
+
+// sigrtmin is the first real-time signal, as the kernel numbers them
+const sigrtmin = 32
+
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	if sig == sigrtmin {
+		return "SIGRTMIN"
+	} else if sig > sigrtmin {
+		return fmt.Sprintf("SIGRT_%d", sig-sigrtmin)
+	}
+	return fmt.Sprintf("%d", sig)
+}
+
+// si_code values, see sigaction(2)
+var siCodes = map[int]string{
+	0x80: "SI_KERNEL",
+	0:    "SI_USER",
+	-1:   "SI_QUEUE",
+	-2:   "SI_TIMER",
+	-3:   "SI_MESGQ",
+	-4:   "SI_ASYNCIO",
+	-5:   "SI_SIGIO",
+	-6:   "SI_TKILL",
+}
+
+var signalCodes = map[syscall.Signal]map[int]string{
+	syscall.SIGCHLD: {1: "CLD_EXITED", 2: "CLD_KILLED", 3: "CLD_DUMPED", 4: "CLD_TRAPPED", 5: "CLD_STOPPED", 6: "CLD_CONTINUED"},
+	syscall.SIGSEGV: {1: "SEGV_MAPERR", 2: "SEGV_ACCERR", 3: "SEGV_BNDERR", 4: "SEGV_PKUERR"},
+	syscall.SIGBUS:  {1: "BUS_ADRALN", 2: "BUS_ADRERR", 3: "BUS_OBJERR", 4: "BUS_MCEERR_AR", 5: "BUS_MCEERR_AO"},
+	syscall.SIGTRAP: {1: "TRAP_BRKPT", 2: "TRAP_TRACE", 3: "TRAP_BRANCH", 4: "TRAP_HWBKPT"},
+}
+
+// formatSiginfo formats siginfo_t like strace, with the fields that
+// matter for the kind of signal
+func formatSiginfo(info syscalls.Siginfo) string {
+	signo := signalName(info.Signo)
+	if name, ok := siCodes[info.Code]; ok {
+		switch info.Code {
+		case 0, -1, -6: // sent by a process
+			return fmt.Sprintf("{si_signo=%s, si_code=%s, si_pid=%d, si_uid=%d}", signo, name, info.Pid, info.Uid)
+		}
+		return fmt.Sprintf("{si_signo=%s, si_code=%s}", signo, name)
+	}
+	code, ok := signalCodes[info.Signo][info.Code]
+	if !ok {
+		return fmt.Sprintf("{si_signo=%s, si_code=%d}", signo, info.Code)
+	}
+	switch info.Signo {
+	case syscall.SIGCHLD:
+		status := fmt.Sprint(info.Status)
+		if code != "CLD_EXITED" {
+			status = signalName(syscall.Signal(info.Status))
+		}
+		return fmt.Sprintf("{si_signo=%s, si_code=%s, si_pid=%d, si_uid=%d, si_status=%s, si_utime=%d, si_stime=%d}",
+			signo, code, info.Pid, info.Uid, status, info.Utime, info.Stime)
+	case syscall.SIGSEGV, syscall.SIGBUS:
+		return fmt.Sprintf("{si_signo=%s, si_code=%s, si_addr=%s}", signo, code, formatValue(syscalls.Hex, int(info.Addr)))
+	}
+	return fmt.Sprintf("{si_signo=%s, si_code=%s}", signo, code)
+}
@@ -0,0 +1,186 @@
+package interceptor
+
+import (
+	"fmt"
+	"io"
+	"strace/syscalls"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// StraceOptions configures the output of Strace
+type StraceOptions struct {
+	WriterOptions
+	FdPaths bool // print fds with their paths, like strace -y
+	Pids    Pids
+}
+
+// Pids tells how lines are prefixed with the thread ID
+type Pids int
+
+const (
+	PidsWhenMany Pids = iota // [pid N] while several threads are traced
+	PidColumn                // always, as strace does with -f -o file
+	NoPids                   // never, as strace does with -ff
+)
+
+// Strace writes syscalls, signals and exits to out formatted like GNU strace
+func Strace(provider Provider, out io.Writer, options StraceOptions) Interceptor {
+	if options.StringLimit == 0 {
+		options.StringLimit = 32
+	}
+	return &straceWriter{
+		decoder:  decoder{provider: provider, stringLimit: options.StringLimit, cQuotes: true, noFdPaths: !options.FdPaths},
+		provider: provider,
+		entered:  map[int]straceEntry{},
+		out:      out,
+		options:  options,
+	}
+}
+
+type straceWriter struct {
+	decoder
+	provider Provider
+	entered  map[int]straceEntry // syscalls not yet returned, by tid
+	open     int                 // tid whose line is not yet terminated
+	column   int                 // of the open line
+	last     time.Time           // start of the last line, for relative timestamps
+	out      io.Writer
+	options  StraceOptions
+}
+
+type straceEntry struct {
+	name  string
+	start time.Time
+}
+
+// returnColumn is where strace aligns " = " to
+const returnColumn = 40
+
+// oTmpfile is __O_TMPFILE, which needs a mode like O_CREAT
+const oTmpfile = 0o20000000
+
+func (w *straceWriter) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	tid := w.provider.Tid()
+	name := strings.ToLower(syscalls.GetName(syscallNum))
+
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	var strs []string
+	for i, kind := range sig.Args[:firstOut(sig)] {
+		if kind == syscalls.Mode && i > 0 && sig.Args[i-1] == syscalls.OpenFlags &&
+			args[i-1]&(syscall.O_CREAT|oTmpfile) == 0 {
+			continue // strace leaves out the mode of open and openat
+		}
+		str := w.formatArg(kind, args, i, 0)
+		strs = append(strs, str)
+	}
+
+	w.startLine(tid)
+	w.write(name + "(" + strings.Join(strs, ", "))
+	if sig.Ret == syscalls.NoReturn {
+		w.endLine(") ", "= ?")
+		return nil
+	}
+	if firstOut(sig) < len(sig.Args) && len(strs) > 0 {
+		w.write(", ")
+	}
+	w.entered[tid] = straceEntry{name, w.provider.EntryTime()}
+	return nil
+}
+
+func (w *straceWriter) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := w.provider.Tid()
+	entry, ok := w.entered[tid]
+	if !ok {
+		entry = straceEntry{strings.ToLower(syscalls.GetName(syscallNum)), w.provider.Time()}
+	}
+	delete(w.entered, tid)
+
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	var strs []string
+	for i := firstOut(sig); i < len(sig.Args); i++ {
+		str := w.formatArg(sig.Args[i], args, i, retVal)
+		strs = append(strs, str)
+	}
+	ret := w.formatReturn(sig, args, retVal)
+
+	w.resume(tid, entry.name)
+	w.write(strings.Join(strs, ", "))
+	ret = "= " + ret
+	if w.options.Duration {
+		ret += formatDuration(w.provider.Time().Sub(entry.start))
+	}
+	w.endLine(") ", ret)
+	return nil
+}
+
+func (w *straceWriter) Signal(tid int, info syscalls.Siginfo) {
+	w.startLine(tid)
+	w.write(fmt.Sprintf("--- %s %s ---\n", signalName(info.Signo), formatSiginfo(info)))
+}
+
+func (w *straceWriter) Exit(tid int, status syscall.WaitStatus) {
+	if entry, ok := w.entered[tid]; ok {
+		// the syscall never returns, like that of a thread when another
+		// calls exit_group
+		delete(w.entered, tid)
+		w.resume(tid, entry.name)
+		w.endLine(") ", "= ?")
+	}
+	w.startLine(tid)
+	switch {
+	case status.Exited():
+		w.write(fmt.Sprintf("+++ exited with %d +++\n", status.ExitStatus()))
+	case status.CoreDump():
+		w.write(fmt.Sprintf("+++ killed by %s (core dumped) +++\n", signalName(status.Signal())))
+	default:
+		w.write(fmt.Sprintf("+++ killed by %s +++\n", signalName(status.Signal())))
+	}
+}
+
+// startLine starts a line of the thread, marking that of another thread
+// as unfinished
+func (w *straceWriter) startLine(tid int) {
+	if w.open != 0 {
+		w.write(" <unfinished ...>\n")
+	}
+	switch {
+	case w.options.Pids == PidColumn:
+		w.write(fmt.Sprintf("%-5d ", tid))
+	case w.options.Pids == PidsWhenMany && w.provider.Tasks() > 1:
+		w.write(fmt.Sprintf("[pid %5d] ", tid))
+	}
+	w.write(w.options.timestamp(w.provider.Time(), &w.last))
+	w.open = tid
+}
+
+// resume continues the open line of the thread, or starts a new one for
+// the rest of its syscall
+func (w *straceWriter) resume(tid int, name string) {
+	if w.open != tid {
+		w.startLine(tid)
+		w.write("<... " + name + " resumed>")
+	}
+}
+
+// endLine ends the open line with the return value aligned
+func (w *straceWriter) endLine(str, ret string) {
+	w.write(str)
+	if w.column < returnColumn {
+		w.write(strings.Repeat(" ", returnColumn-w.column))
+	}
+	w.write(ret + "\n")
+}
+
+func (w *straceWriter) write(str string) {
+	if i := strings.LastIndexByte(str, '\n'); i >= 0 {
+		w.open = 0
+		w.column = len(str) - i - 1
+	} else {
+		w.column += len(str)
+	}
+	_, _ = io.WriteString(w.out, str)
+}
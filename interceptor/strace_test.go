@@ -0,0 +1,34 @@
+package interceptor
+
+import (
+	"strace/syscalls"
+	"syscall"
+	"testing"
+)
+
+func TestQuoteC(t *testing.T) {
+	for str, expected := range map[string]string{
+		"hi\n":                    `"hi\n"`,
+		"\x7fELF\x02\x01\x01\x00": `"\177ELF\2\1\1\0"`,
+		"\x001":                   `"\0001"`,
+		"\x00a":                   `"\0a"`,
+		"\"\\\t\xff":              `"\"\\\t\377"`,
+	} {
+		if quoted := quoteC(str); quoted != expected {
+			t.Errorf("expected %s, but got %s", expected, quoted)
+		}
+	}
+}
+
+func TestFormatSiginfo(t *testing.T) {
+	for expected, info := range map[string]syscalls.Siginfo{
+		"{si_signo=SIGTERM, si_code=SI_USER, si_pid=1, si_uid=0}": {Signo: syscall.SIGTERM, Code: 0, Pid: 1},
+		"{si_signo=SIGCHLD, si_code=CLD_KILLED, si_pid=7, si_uid=0, si_status=SIGKILL, si_utime=1, si_stime=0}": {
+			Signo: syscall.SIGCHLD, Code: 2, Pid: 7, Status: int(syscall.SIGKILL), Utime: 1},
+		"{si_signo=SIGSEGV, si_code=SEGV_MAPERR, si_addr=NULL}": {Signo: syscall.SIGSEGV, Code: 1},
+	} {
+		if str := formatSiginfo(info); str != expected {
+			t.Errorf("expected %s, but got %s", expected, str)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"strace/syscalls"
+	"strings"
+	"syscall"
+)
+
+// Kernel structs not in the syscall package, or with pointers in it.
+// Layouts are those of the 64-bit architectures, amd64 and arm64.
+type iovec struct {
+	Base, Len uint64
+}
+
+type pollfd struct {
+	Fd              int32
+	Events, Revents int16
+}
+
+// sigaction is the struct of rt_sigaction, not that of the C library
+type sigaction struct {
+	Handler, Flags, Restorer uint64
+	Mask                     uint64 // sigset_t of 64 signals
+}
+
+const rlimInfinity = ^uint64(0)
+
+// read reads a fixed-size value from the tracee's memory
+func (d decoder) read(addr int, v any) error {
+	buf := make([]byte, binary.Size(v))
+	if err := d.provider.ReadMemory(uintptr(addr), buf); err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(buf), binary.NativeEndian, v)
+}
+
+func (d decoder) formatStruct(kind syscalls.ArgKind, addr int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	var str string
+	switch kind {
+	case syscalls.Stat:
+		var stat syscall.Stat_t
+		if err := d.read(addr, &stat); err != nil {
+			return "", fmt.Errorf("reading stat: %w", err)
+		}
+		str = formatStat(stat)
+	case syscalls.Timespec, syscalls.TimespecOut, syscalls.TimespecRem:
+		var ts syscall.Timespec
+		if err := d.read(addr, &ts); err != nil {
+			return "", fmt.Errorf("reading timespec: %w", err)
+		}
+		str = fmt.Sprintf("{tv_sec=%d, tv_nsec=%d}", ts.Sec, ts.Nsec)
+	case syscalls.Rlimit, syscalls.RlimitOut:
+		var rlim syscall.Rlimit
+		if err := d.read(addr, &rlim); err != nil {
+			return "", fmt.Errorf("reading rlimit: %w", err)
+		}
+		str = fmt.Sprintf("{rlim_cur=%s, rlim_max=%s}", formatRlim(rlim.Cur), formatRlim(rlim.Max))
+	case syscalls.Sigset, syscalls.SigsetOut:
+		var mask uint64
+		if err := d.read(addr, &mask); err != nil {
+			return "", fmt.Errorf("reading sigset: %w", err)
+		}
+		str = formatSigset(mask)
+	case syscalls.Sigaction, syscalls.SigactionOut:
+		var sa sigaction
+		if err := d.read(addr, &sa); err != nil {
+			return "", fmt.Errorf("reading sigaction: %w", err)
+		}
+		str = formatSigaction(sa)
+	}
+	return str, nil
+}
+
+var fileTypes = map[uint32]string{
+	syscall.S_IFBLK:  "S_IFBLK",
+	syscall.S_IFCHR:  "S_IFCHR",
+	syscall.S_IFDIR:  "S_IFDIR",
+	syscall.S_IFIFO:  "S_IFIFO",
+	syscall.S_IFLNK:  "S_IFLNK",
+	syscall.S_IFREG:  "S_IFREG",
+	syscall.S_IFSOCK: "S_IFSOCK",
+}
+
+func formatStat(stat syscall.Stat_t) string {
+	mode := fmt.Sprintf("%#o", stat.Mode&^syscall.S_IFMT)
+	if name, ok := fileTypes[stat.Mode&syscall.S_IFMT]; ok {
+		mode = name + "|" + mode
+	}
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK, syscall.S_IFCHR:
+		// major and minor as in glibc's gnu_dev_major and gnu_dev_minor
+		major := stat.Rdev>>32&0xfffff000 | stat.Rdev>>8&0xfff
+		minor := stat.Rdev>>12&0xffffff00 | stat.Rdev&0xff
+		return fmt.Sprintf("{st_mode=%s, st_rdev=makedev(%#x, %#x), ...}", mode, major, minor)
+	}
+	return fmt.Sprintf("{st_mode=%s, st_size=%d, ...}", mode, stat.Size)
+}
+
+// formatRlim formats a limit like strace, in KiB where it is a multiple
+func formatRlim(rlim uint64) string {
+	if rlim == rlimInfinity {
+		return "RLIM64_INFINITY"
+	} else if rlim > 1024 && rlim%1024 == 0 {
+		return fmt.Sprintf("%d*1024", rlim/1024)
+	}
+	return fmt.Sprint(rlim)
+}
+
+// formatSigset formats a signal mask like strace, by signal names without
+// SIG, or as ~ and the signals not in it when it has most of them
+func formatSigset(mask uint64) string {
+	prefix := "["
+	if bits.OnesCount64(mask) >= 64*2/3 {
+		prefix, mask = "~[", ^mask
+	}
+	var names []string
+	for sig := 1; sig <= 64; sig++ {
+		if mask&(1<<(sig-1)) != 0 {
+			names = append(names, strings.TrimPrefix(signalName(syscall.Signal(sig)), "SIG"))
+		}
+	}
+	return prefix + strings.Join(names, " ") + "]"
+}
+
+const saRestorer = 0x04000000
+
+// saFlags are the SA_* flags, in the order strace prints them
+var saFlags = []struct {
+	name  string
+	value uint64
+}{
+	{"SA_RESTORER", saRestorer},
+	{"SA_ONSTACK", 0x08000000},
+	{"SA_RESTART", 0x10000000},
+	{"SA_NODEFER", 0x40000000},
+	{"SA_RESETHAND", 0x80000000},
+	{"SA_SIGINFO", 0x4},
+	{"SA_NOCLDSTOP", 0x1},
+	{"SA_NOCLDWAIT", 0x2},
+}
+
+func formatSigaction(sa sigaction) string {
+	handler := fmt.Sprintf("%#x", sa.Handler)
+	switch sa.Handler {
+	case 0:
+		handler = "SIG_DFL"
+	case 1:
+		handler = "SIG_IGN"
+	}
+	var strs []string
+	rest := sa.Flags
+	for _, f := range saFlags {
+		if rest&f.value != 0 {
+			strs = append(strs, f.name)
+			rest &^= f.value
+		}
+	}
+	if rest != 0 {
+		strs = append(strs, fmt.Sprintf("%#x", rest))
+	} else if len(strs) == 0 {
+		strs = append(strs, "0")
+	}
+	str := fmt.Sprintf("{sa_handler=%s, sa_mask=%s, sa_flags=%s",
+		handler, formatSigset(sa.Mask), strings.Join(strs, "|"))
+	if sa.Flags&saRestorer != 0 {
+		str += fmt.Sprintf(", sa_restorer=%#x", sa.Restorer)
+	}
+	return str + "}"
+}
+
+// formatIovecs formats count iovecs. The contents of all of them are
+// shown when written by the process, otherwise the first size bytes.
+func (d decoder) formatIovecs(addr, count, size int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	var strs []string
+	for i := 0; i < count; i++ {
+		if i == d.stringLimit {
+			strs = append(strs, "...")
+			break
+		}
+		var iov iovec
+		if err := d.read(addr+i*binary.Size(iov), &iov); err != nil {
+			return "", fmt.Errorf("reading iovec %d: %w", i, err)
+		}
+		n := int(iov.Len)
+		if size >= 0 {
+			n = min(n, size)
+			size -= n
+		}
+		base, err := d.readBuf(int(iov.Base), n)
+		if err != nil {
+			return "", fmt.Errorf("iovec %d: %w", i, err)
+		}
+		strs = append(strs, fmt.Sprintf("{iov_base=%s, iov_len=%d}", base, iov.Len))
+	}
+	return "[" + strings.Join(strs, ", ") + "]", nil
+}
+
+// formatSockaddr formats a socket address of length size
+func (d decoder) formatSockaddr(addr, size int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	var family uint16
+	if size < binary.Size(family) {
+		return formatValue(syscalls.Hex, addr), nil
+	}
+	if err := d.read(addr, &family); err != nil {
+		return "", fmt.Errorf("reading sockaddr: %w", err)
+	}
+	familyName := syscalls.FormatFlags(syscalls.Family, int(family))
+
+	switch family {
+	case syscall.AF_INET:
+		var sa syscall.RawSockaddrInet4
+		if err := d.read(addr, &sa); err != nil {
+			return "", fmt.Errorf("reading sockaddr_in: %w", err)
+		}
+		return fmt.Sprintf(`{sa_family=%s, sin_port=htons(%d), sin_addr=inet_addr("%s")}`,
+			familyName, ntohs(sa.Port), net.IP(sa.Addr[:])), nil
+	case syscall.AF_INET6:
+		var sa syscall.RawSockaddrInet6
+		if err := d.read(addr, &sa); err != nil {
+			return "", fmt.Errorf("reading sockaddr_in6: %w", err)
+		}
+		return fmt.Sprintf(`{sa_family=%s, sin6_port=htons(%d), sin6_flowinfo=htonl(%d), `+
+			`inet_pton(%s, "%s", &sin6_addr), sin6_scope_id=%d}`,
+			familyName, ntohs(sa.Port), ntohl(sa.Flowinfo), familyName, net.IP(sa.Addr[:]), sa.Scope_id), nil
+	case syscall.AF_UNIX:
+		// the path is up to size, NUL-terminated or not
+		n := min(size, binary.Size(syscall.RawSockaddrUnix{})) - binary.Size(family)
+		path, err := d.provider.ReadPtraceTextBuf(uintptr(addr+binary.Size(family)), max(n, 0))
+		if err != nil {
+			return "", fmt.Errorf("reading sockaddr_un: %w", err)
+		}
+		if abstract, found := strings.CutPrefix(path, "\x00"); found && n > 1 {
+			return fmt.Sprintf("{sa_family=%s, sun_path=@%s}", familyName, d.quote(abstract)), nil
+		}
+		path, _, _ = strings.Cut(path, "\x00")
+		return fmt.Sprintf("{sa_family=%s, sun_path=%s}", familyName, d.quote(path)), nil
+	}
+	return fmt.Sprintf("{sa_family=%s, ...}", familyName), nil
+}
+
+// formatPollfds formats count pollfds, with their events at entry or
+// the returned events at exit, where those without any are left out
+func (d decoder) formatPollfds(addr, count int, returned bool) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	var strs []string
+	for i := 0; i < count; i++ {
+		if len(strs) == d.stringLimit {
+			strs = append(strs, "...")
+			break
+		}
+		var fd pollfd
+		if err := d.read(addr+i*binary.Size(fd), &fd); err != nil {
+			return "", fmt.Errorf("reading pollfd %d: %w", i, err)
+		}
+		if !returned {
+			strs = append(strs, fmt.Sprintf("{fd=%d, events=%s}",
+				fd.Fd, syscalls.FormatFlags(syscalls.PollEvents, int(uint16(fd.Events)))))
+		} else if fd.Revents != 0 {
+			strs = append(strs, fmt.Sprintf("{fd=%d, revents=%s}",
+				fd.Fd, syscalls.FormatFlags(syscalls.PollEvents, int(uint16(fd.Revents)))))
+		}
+	}
+	return "[" + strings.Join(strs, ", ") + "]", nil
+}
+
+// ntohs converts from network byte order
+func ntohs(n uint16) uint16 {
+	return binary.BigEndian.Uint16(binary.NativeEndian.AppendUint16(nil, n))
+}
+
+func ntohl(n uint32) uint32 {
+	return binary.BigEndian.Uint32(binary.NativeEndian.AppendUint32(nil, n))
+}
+
+// formatStrArray formats a NULL-terminated array of strings, like argv
+func (d decoder) formatStrArray(addr int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	var strs []string
+	for i := 0; ; i++ {
+		var ptr uint64
+		if err := d.read(addr+i*binary.Size(ptr), &ptr); err != nil {
+			return "", fmt.Errorf("reading array element %d: %w", i, err)
+		}
+		if ptr == 0 {
+			break
+		}
+		if i == d.stringLimit {
+			strs = append(strs, "...")
+			break
+		}
+		str, err := d.provider.ReadPtraceText(uintptr(ptr))
+		if err != nil {
+			return "", fmt.Errorf("reading array element %d: %w", i, err)
+		}
+		strs = append(strs, d.shortString(str))
+	}
+	return "[" + strings.Join(strs, ", ") + "]", nil
+}
+
+// formatEnviron formats a NULL-terminated array of strings by its length,
+// like envp
+func (d decoder) formatEnviron(addr int) (string, error) {
+	if addr == 0 {
+		return "NULL", nil
+	}
+	n := 0
+	for ; ; n++ {
+		var ptr uint64
+		if err := d.read(addr+n*binary.Size(ptr), &ptr); err != nil {
+			return "", fmt.Errorf("reading array element %d: %w", n, err)
+		}
+		if ptr == 0 {
+			break
+		}
+	}
+	if n == 1 {
+		return fmt.Sprintf("%#x /* 1 var */", addr), nil
+	}
+	return fmt.Sprintf("%#x /* %d vars */", addr, n), nil
+}
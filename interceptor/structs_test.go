@@ -0,0 +1,49 @@
+package interceptor
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// binary.Read skips no padding, so the layouts must not have any
+func TestStructLayouts(t *testing.T) {
+	for name, size := range map[string][2]int{
+		"stat":         {binary.Size(syscall.Stat_t{}), int(unsafe.Sizeof(syscall.Stat_t{}))},
+		"timespec":     {binary.Size(syscall.Timespec{}), int(unsafe.Sizeof(syscall.Timespec{}))},
+		"rlimit":       {binary.Size(syscall.Rlimit{}), int(unsafe.Sizeof(syscall.Rlimit{}))},
+		"iovec":        {binary.Size(iovec{}), int(unsafe.Sizeof(syscall.Iovec{}))},
+		"pollfd":       {binary.Size(pollfd{}), 8},
+		"sigaction":    {binary.Size(sigaction{}), 32},
+		"sockaddr_in":  {binary.Size(syscall.RawSockaddrInet4{}), syscall.SizeofSockaddrInet4},
+		"sockaddr_in6": {binary.Size(syscall.RawSockaddrInet6{}), syscall.SizeofSockaddrInet6},
+		"sockaddr_un":  {binary.Size(syscall.RawSockaddrUnix{}), syscall.SizeofSockaddrUnix},
+	} {
+		if size[0] != size[1] {
+			t.Errorf("expected size %d of %s, but got %d", size[1], name, size[0])
+		}
+	}
+}
+
+func TestFormatSigaction(t *testing.T) {
+	for expected, mask := range map[string]uint64{
+		"[]":            0,
+		"[INT TERM]":    1<<(syscall.SIGINT-1) | 1<<(syscall.SIGTERM-1),
+		"~[RTMIN RT_1]": ^uint64(3 << 31),
+		"~[]":           ^uint64(0),
+		"[CHLD RT_32]":  1<<(syscall.SIGCHLD-1) | 1<<63,
+	} {
+		if str := formatSigset(mask); str != expected {
+			t.Errorf("expected %s, but got %s", expected, str)
+		}
+	}
+	sa := sigaction{Handler: 1, Flags: saRestorer | 0x10000000 | 0x4, Restorer: 0x1000}
+	expected := "{sa_handler=SIG_IGN, sa_mask=[], sa_flags=SA_RESTORER|SA_RESTART|SA_SIGINFO, sa_restorer=0x1000}"
+	if str := formatSigaction(sa); str != expected {
+		t.Errorf("expected %s, but got %s", expected, str)
+	}
+	if str := formatSigaction(sigaction{}); str != "{sa_handler=SIG_DFL, sa_mask=[], sa_flags=0}" {
+		t.Errorf("expected no flags, but got %s", str)
+	}
+}
@@ -0,0 +1,204 @@
+package interceptor
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strace/syscalls"
+	"strings"
+	"time"
+)
+
+// Summarizer is an Interceptor collecting statistics to print when tracing
+// has ended
+type Summarizer interface {
+	Interceptor
+	Print(out io.Writer) error
+}
+
+// SummaryOptions configures the tables of Summary
+type SummaryOptions struct {
+	SortBy     SortBy // column to sort rows by, "" for time
+	PerProcess bool   // print a table per process before the one of all
+}
+
+// SortBy is a column of the summary table
+type SortBy string
+
+const (
+	SortTime    SortBy = "time" // total time spent
+	SortAvgTime SortBy = "avg-time"
+	SortMaxTime SortBy = "max-time"
+	SortCalls   SortBy = "calls"
+	SortErrors  SortBy = "errors"
+	SortName    SortBy = "name"
+	SortNothing SortBy = "nothing" // in order of syscall numbers
+)
+
+func (s *SortBy) String() string {
+	return string(*s)
+}
+
+func (s *SortBy) Set(value string) error {
+	switch sortBy := SortBy(value); sortBy {
+	case SortTime, SortAvgTime, SortMaxTime, SortCalls, SortErrors, SortName, SortNothing:
+		*s = sortBy
+		return nil
+	}
+	return fmt.Errorf(`unknown column "%s"`, value)
+}
+
+// Summary counts calls, errors and time spent per syscall, like `strace -c`
+func Summary(provider Provider, options SummaryOptions) Summarizer {
+	if options.SortBy == "" {
+		options.SortBy = SortTime
+	}
+	return &summary{provider, options, map[int]time.Time{}, statsTable{}, map[int]statsTable{}}
+}
+
+type summary struct {
+	provider Provider
+	options  SummaryOptions
+	start    map[int]time.Time // syscall entry, by tid
+	stats    statsTable
+	perPid   map[int]statsTable
+}
+
+// statsTable holds the stats by syscall number
+type statsTable map[int]*syscallStats
+
+type syscallStats struct {
+	calls  int
+	errors int
+	time   time.Duration
+	max    time.Duration
+}
+
+func (s *summary) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	s.start[s.provider.Tid()] = s.provider.EntryTime()
+	return nil
+}
+
+func (s *summary) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := s.provider.Tid()
+	start, ok := s.start[tid]
+	if !ok {
+		return nil // entry not seen
+	}
+	delete(s.start, tid)
+	duration := s.provider.Time().Sub(start)
+
+	s.stats.add(syscallNum, duration, retVal)
+	if s.options.PerProcess {
+		pid := s.provider.Pid()
+		if s.perPid[pid] == nil {
+			s.perPid[pid] = statsTable{}
+		}
+		s.perPid[pid].add(syscallNum, duration, retVal)
+	}
+	return nil
+}
+
+func (t statsTable) add(syscallNum int, duration time.Duration, retVal int) {
+	stats, ok := t[syscallNum]
+	if !ok {
+		stats = &syscallStats{}
+		t[syscallNum] = stats
+	}
+	stats.calls++
+	stats.time += duration
+	stats.max = max(stats.max, duration)
+	if syscalls.IsError(retVal) {
+		stats.errors++
+	}
+}
+
+func (s *syscallStats) avg() time.Duration {
+	return s.time / time.Duration(s.calls)
+}
+
+// Print writes the tables, sorted by the chosen column
+func (s *summary) Print(out io.Writer) error {
+	str := ""
+	if s.options.PerProcess {
+		pids := make([]int, 0, len(s.perPid))
+		for pid := range s.perPid {
+			pids = append(pids, pid)
+		}
+		slices.Sort(pids)
+		for _, pid := range pids {
+			str += fmt.Sprintf("Process %d:\n", pid) + s.table(s.perPid[pid]) + "\n"
+		}
+		str += "All processes:\n"
+	}
+	str += s.table(s.stats)
+	_, err := io.WriteString(out, str)
+	return err
+}
+
+func (s *summary) table(stats statsTable) string {
+	nums := make([]int, 0, len(stats))
+	var total syscallStats
+	for num, stats := range stats {
+		nums = append(nums, num)
+		total.calls += stats.calls
+		total.errors += stats.errors
+		total.time += stats.time
+		total.max = max(total.max, stats.max)
+	}
+	slices.SortFunc(nums, func(a, b int) int {
+		if c := s.compare(stats[a], stats[b]); c != 0 {
+			return c
+		}
+		if s.options.SortBy == SortNothing {
+			return cmp.Compare(a, b)
+		}
+		return cmp.Compare(syscalls.GetName(a), syscalls.GetName(b))
+	})
+
+	line := "------ ----------- ----------- ----------- --------- --------- ----------------\n"
+	str := "% time     seconds  usecs/call   usecs/max     calls    errors syscall\n" + line
+	for _, num := range nums {
+		stats := stats[num]
+		str += fmt.Sprintf("%6.2f %11.6f %11d %11d %9d %9s %s\n",
+			percent(stats.time, total.time), stats.time.Seconds(),
+			stats.avg().Microseconds(), stats.max.Microseconds(), stats.calls,
+			blankZero(stats.errors), strings.ToLower(syscalls.GetName(num)))
+	}
+	str += line
+	str += fmt.Sprintf("%6.2f %11.6f %11s %11d %9d %9s total\n",
+		100.0, total.time.Seconds(), "", total.max.Microseconds(), total.calls, blankZero(total.errors))
+	return str
+}
+
+// compare orders rows by the chosen column, biggest first
+func (s *summary) compare(a, b *syscallStats) int {
+	switch s.options.SortBy {
+	case SortAvgTime:
+		return cmp.Compare(b.avg(), a.avg())
+	case SortMaxTime:
+		return cmp.Compare(b.max, a.max)
+	case SortCalls:
+		return cmp.Compare(b.calls, a.calls)
+	case SortErrors:
+		return cmp.Compare(b.errors, a.errors)
+	case SortName, SortNothing:
+		return 0
+	}
+	return cmp.Compare(b.time, a.time)
+}
+
+func percent(part, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+func blankZero(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
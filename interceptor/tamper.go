@@ -0,0 +1,197 @@
+package interceptor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Rule tampers with the syscalls its Trigger selects, which are still
+// made, as given by a line like
+//
+//	read:fd=3:arg3<=16            read at most 16 bytes, for partial reads
+//	write:path=out.txt:arg3<=1    write one byte at a time, for short writes
+//	openat:arg3&~=O_CREAT         never create files
+//	openat:arg3|=O_EXCL           or only new ones
+//	fsync:retval=0                pretend to succeed
+//	close:when=2:error=EIO        pretend to fail
+//
+// Arguments are numbered from 1, and set with =, lowered to at most <=,
+// or have flags set with |= or cleared with &~=. Values are numbers or
+// flag names joined by |. The return value is set at exit, after the
+// syscall has been made: a read given a smaller retval has still moved the
+// file offset by all it read, so shrink its size instead.
+type Rule struct {
+	Trigger
+	Args      []ArgChange
+	SetRetVal bool // whether to return RetVal
+	RetVal    int
+}
+
+// ArgChange changes argument N at syscall entry
+type ArgChange struct {
+	N     int
+	Op    string // =, <=, |= or &~=
+	Value int
+}
+
+func (c ArgChange) apply(value int) int {
+	switch c.Op {
+	case "<=":
+		return min(value, c.Value)
+	case "|=":
+		return value | c.Value
+	case "&~=":
+		return value &^ c.Value
+	}
+	return c.Value
+}
+
+// ParseRules parses rules, one a line, with # comments
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		rule, err := parseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func parseRule(text string) (Rule, error) {
+	elems := strings.Split(text, ":")
+	set, negated := strings.CutPrefix(elems[0], "!")
+	trigger, err := newTrigger(set, negated)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{Trigger: trigger}
+	for _, elem := range elems[1:] {
+		key, value, _ := strings.Cut(elem, "=")
+		if ok, err := rule.parse(key, value); ok {
+			if err != nil {
+				return rule, err
+			}
+			continue
+		}
+		switch {
+		case key == "retval":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return rule, fmt.Errorf(`invalid retval "%s"`, value)
+			}
+			rule.RetVal, rule.SetRetVal = n, true
+		case key == "error":
+			errno, err := parseErrno(value)
+			if err != nil {
+				return rule, err
+			}
+			rule.RetVal, rule.SetRetVal = -errno, true
+		case strings.HasPrefix(key, "arg"):
+			change, err := parseArgChange(key, value)
+			if err != nil {
+				return rule, err
+			}
+			rule.Args = append(rule.Args, change)
+		default:
+			return rule, fmt.Errorf(`unsupported rule argument "%s"`, elem)
+		}
+	}
+	if len(rule.Args) == 0 && !rule.SetRetVal {
+		return rule, fmt.Errorf(`rule needs an argument or return value to change in "%s"`, text)
+	}
+	return rule, nil
+}
+
+// parseArgChange parses the key and value of argN=value and the like
+func parseArgChange(key, value string) (ArgChange, error) {
+	var change ArgChange
+	num := strings.TrimPrefix(key, "arg")
+	for _, op := range []string{"<", "|", "&~"} {
+		if n, ok := strings.CutSuffix(num, op); ok {
+			num, change.Op = n, op
+			break
+		}
+	}
+	change.Op += "="
+	var err error
+	if change.N, err = strconv.Atoi(num); err != nil || change.N < 1 || change.N > 6 {
+		return change, fmt.Errorf(`invalid argument "%s"`, key)
+	}
+	for _, str := range strings.Split(value, "|") {
+		if flag, ok := syscalls.GetFlag(str); ok {
+			change.Value |= flag
+			continue
+		}
+		n, err := strconv.ParseInt(str, 0, 64)
+		if err != nil {
+			return change, fmt.Errorf(`invalid value "%s"`, str)
+		}
+		change.Value |= int(n)
+	}
+	return change, nil
+}
+
+// Tamper changes the arguments and return values of syscalls as the rules
+// tell. All rules that fire apply, in order. Probabilities are drawn from a
+// source seeded with seed.
+func Tamper(provider Provider, rules []Rule, seed int64) Interceptor {
+	return &tamperer{provider, rules, map[int]int{}, rand.New(rand.NewSource(seed))}
+}
+
+type tamperer struct {
+	provider Provider
+	rules    []Rule
+	retVals  map[int]int // to return at exit, by tid
+	rand     *rand.Rand
+}
+
+func (t *tamperer) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	for n := range t.rules {
+		rule := &t.rules[n]
+		if !rule.fire(t.provider, syscallNum, args, t.rand) {
+			continue
+		}
+		for _, change := range rule.Args {
+			value := change.apply(args[change.N-1])
+			if err := t.provider.SetArg(change.N, value); err != nil {
+				return err
+			}
+			args[change.N-1] = value
+		}
+		if rule.SetRetVal {
+			t.retVals[t.provider.Tid()] = rule.RetVal
+		}
+	}
+	return nil
+}
+
+func (t *tamperer) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := t.provider.Tid()
+	value, ok := t.retVals[tid]
+	if !ok {
+		return nil
+	}
+	delete(t.retVals, tid)
+	return t.provider.SetRetVal(value)
+}
+
+func (t *tamperer) Signal(tid int, info syscalls.Siginfo) {}
+
+func (t *tamperer) Exit(tid int, status syscall.WaitStatus) {
+	delete(t.retVals, tid)
+}
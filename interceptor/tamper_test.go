@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+# partial reads
+read:fd=3:arg3<=16
+openat:arg3&~=O_CREAT|O_EXCL # never create files
+close:when=2:error=EIO
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules, but got %d", len(rules))
+	}
+	for _, test := range []struct {
+		change   ArgChange
+		value    int
+		expected int
+	}{
+		{rules[0].Args[0], 4096, 16},
+		{rules[0].Args[0], 8, 8},
+		{rules[1].Args[0], syscall.O_WRONLY | syscall.O_CREAT | syscall.O_EXCL, syscall.O_WRONLY},
+		{ArgChange{2, "|=", syscall.O_APPEND}, syscall.O_WRONLY, syscall.O_WRONLY | syscall.O_APPEND},
+		{ArgChange{2, "=", 0}, 7, 0},
+	} {
+		if value := test.change.apply(test.value); value != test.expected {
+			t.Errorf("%+v: expected %#x for %#x, but got %#x", test.change, test.expected, test.value, value)
+		}
+	}
+	if !rules[0].Fds.Fds[3] || rules[2].RetVal != -int(syscall.EIO) || !rules[2].SetRetVal || rules[2].First != 2 {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, text := range []string{"read", "read:arg7=1", "read:arg3<=x", "read:arg=1", "read:retval=x", "nothing:retval=0"} {
+		if _, err := ParseRules(strings.NewReader("\n" + text)); err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+			t.Errorf("%s: expected an error on line 2, but got %v", text, err)
+		}
+	}
+}
@@ -2,114 +2,163 @@ package interceptor
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strace/syscalls"
-	"strconv"
 	"strings"
-	"syscall"
+	"time"
 )
 
-// Writer writes syscalls to the console stdout
-func Writer(provider Provider) Interceptor {
-	return &writer{provider, "", os.Stderr}
+// WriterOptions configures the output of Writer
+type WriterOptions struct {
+	StringLimit int        // bytes of buffers to print, 0 for the default 32
+	Time        Timestamps // prefix lines with the time
+	Relative    bool       // prefix lines with the time since the last line started
+	Duration    bool       // append the time spent in each syscall
+}
+
+// Timestamps selects the absolute time prefixed to lines
+type Timestamps int
+
+const (
+	NoTimestamps    Timestamps = iota
+	TimeOfDay                  // 15:04:05, like strace -t
+	TimeOfDayMicros            // 15:04:05.000000, like strace -tt
+	UnixMicros                 // seconds since the epoch, 1700000000.000000, like strace -ttt
+)
+
+// timestamp formats the time prefix of a line starting at now, updating
+// last
+func (o WriterOptions) timestamp(now time.Time, last *time.Time) string {
+	str := ""
+	switch o.Time {
+	case TimeOfDay:
+		str = now.Format("15:04:05 ")
+	case TimeOfDayMicros:
+		str = now.Format("15:04:05.000000 ")
+	case UnixMicros:
+		str = fmt.Sprintf("%d.%06d ", now.Unix(), now.Nanosecond()/1000)
+	}
+	if o.Relative {
+		var relative time.Duration
+		if !last.IsZero() {
+			relative = now.Sub(*last)
+		}
+		str += fmt.Sprintf("%6d.%06d ", relative/time.Second, relative%time.Second/time.Microsecond)
+	}
+	*last = now
+	return str
+}
+
+// formatDuration formats the time spent in a syscall, like strace -T
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf(" <%d.%06d>", d/time.Second, d%time.Second/time.Microsecond)
+}
+
+// Writer writes syscalls to out
+func Writer(provider Provider, out io.Writer, options WriterOptions) Interceptor {
+	if options.StringLimit == 0 {
+		options.StringLimit = 32
+	}
+	return &writer{
+		decoder:  decoder{provider: provider, stringLimit: options.StringLimit},
+		provider: provider,
+		start:    map[int]time.Time{},
+		out:      out,
+		options:  options,
+	}
 }
 
 type writer struct {
+	decoder
 	provider Provider
-	path     string
-	fd       *os.File
+	start    map[int]time.Time // syscall entry, by tid
+	open     int               // tid whose line is not yet terminated
+	last     time.Time         // start of the last line, for relative timestamps
+	out      io.Writer
+	options  WriterOptions
 }
 
-var syscall_OPEN = -1
-var openPathArg2 = false
-
-func (w *writer) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) {
+func (w *writer) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	tid := w.provider.Tid()
+	w.start[tid] = w.provider.EntryTime()
 	syscallName := strings.ToLower(syscalls.GetName(syscallNum))
 
-	str := syscallName
-
-	switch syscallNum {
-	case syscall.SYS_GETUID, syscall.SYS_GETEUID:
-		// uid_t get[e]uid(void)
-		str += fmt.Sprintf(`() `)
-	case syscall_OPEN:
-		// int open(const char *path, int oflag, ...)
-		// int openat(int dirfd, const char *pathname, int flags
-		openPathArg := arg1
-		if openPathArg2 {
-			openPathArg = arg2
-		}
-		w.path = w.provider.ReadPtraceText(uintptr(openPathArg))
-		str += fmt.Sprintf(`("%s", %d) `, w.path, arg2)
-	case syscall.SYS_READ:
-		// ssize_t read(int fildes, void *buf, size_t nbyte)
-		fd := formatFileDesc(arg1, w.provider.FileName(arg1))
-		str += fmt.Sprintf("(%s, %d, %d)\n", fd, arg2, arg3)
-	case syscall.SYS_LSEEK:
-		// off_t lseek(int fildes, off_t offset, int whence)
-		// If whence is SEEK_SET, the file offset shall be set to offset bytes.
-		// If whence is SEEK_CUR, the file offset shall be set to its current location plus offset.
-		// If whence is SEEK_END, the file offset shall be set to the size of the file plus offset.
-		// https://pubs.opengroup.org/onlinepubs/009696799/functions/lseek.html
-		fd := formatFileDesc(arg1, w.provider.FileName(arg1))
-		whence := map[int]string{0: "SEEK_SET", 1: "SEEK_CUR", 2: "SEEK_END"}
-		str += fmt.Sprintf(`(%s, %d, %s) `, fd, arg2, whence[arg3])
-	case syscall.SYS_MMAP:
-		// void * mmap(void *addr, size_t len, int prot, int flags, int fd, off_t offset)
-		str += fmt.Sprintf("(%d, %d, %d, %d, %d, %d)\n",
-			arg1, arg2, arg3, arg4, arg5, arg6)
-	case syscall.SYS_WRITE:
-		// ssize_t write(int fd, const void *buf, size_t count)
-		buf := shortString(w.provider.ReadPtraceTextBuf(uintptr(arg2), arg3))
-		str += fmt.Sprintf(`(%d, %q, %d) `, arg1, buf, arg3)
-	default:
-		str += "\n"
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	var strs []string
+	for i, kind := range sig.Args[:firstOut(sig)] {
+		str := w.formatArg(kind, args, i, 0)
+		strs = append(strs, str)
 	}
 
-	_, _ = w.fd.WriteString(fmt.Sprintf("%s", str))
+	str := fmt.Sprintf("%s(%s", syscallName, strings.Join(strs, ", "))
+	if firstOut(sig) < len(sig.Args) {
+		// the rest is written by the kernel
+		if len(strs) > 0 {
+			str += ", "
+		}
+		w.write(tid, str)
+		return nil
+	}
+	str += ") "
+	if sig.Ret == syscalls.NoReturn {
+		str += "= ?\n"
+	}
+	w.write(tid, str)
+	return nil
 }
 
-func (w *writer) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) {
+func (w *writer) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	tid := w.provider.Tid()
 
+	sig := syscalls.GetSignature(syscallNum)
+	args := []int{arg1, arg2, arg3, arg4, arg5, arg6}
+	var strs []string
+	for i := firstOut(sig); i < len(sig.Args); i++ {
+		str := w.formatArg(sig.Args[i], args, i, retVal)
+		strs = append(strs, str)
+	}
 	str := ""
-
-	switch syscallNum {
-	case syscall_OPEN:
-		// int open(const char *path, int oflag, ...)
-		fd := retVal
-		str += fmt.Sprintf(`%d`, fd)
-		w.provider.PutFileDescriptor(fd, w.path)
-	case syscall.SYS_READ:
-		// ssize_t read(int fildes, void *buf, size_t nbyte)
-		if retVal <= arg3 {
-			buf := shortString(w.provider.ReadPtraceTextBuf(uintptr(arg2), retVal))
-			str += fmt.Sprintf(`%d: %s`, retVal, buf)
-		} else {
-			str += fmt.Sprintf(`%d`, retVal)
-		}
-	case syscall.SYS_GETUID, syscall.SYS_GETEUID,
-		syscall.SYS_LSEEK,
-		syscall.SYS_WRITE:
-		str += fmt.Sprintf(`%d`, retVal)
+	if firstOut(sig) < len(sig.Args) {
+		str = strings.Join(strs, ", ") + ") "
 	}
 
-	if len(str) > 0 {
-		_, _ = w.fd.WriteString(fmt.Sprintf("= %s\n", str))
+	ret := w.formatReturn(sig, args, retVal)
+	str += "= " + ret
+
+	if start, ok := w.start[tid]; ok && w.options.Duration {
+		str += formatDuration(w.provider.Time().Sub(start))
 	}
+	delete(w.start, tid)
+	w.write(tid, str+"\n")
+	return nil
 }
 
-func formatFileDesc(fd int, path string) string {
-	if path != "" {
-		return fmt.Sprintf(`%d<%s>`, fd, path)
-	} else {
-		return strconv.Itoa(fd)
+// firstOut returns the index of the first argument written by the kernel,
+// or the number of arguments
+func firstOut(sig syscalls.Signature) int {
+	for i, kind := range sig.Args {
+		if kind.Out() {
+			return i
+		}
 	}
+	return len(sig.Args)
 }
 
-func shortString(buf string) interface{} {
-	buf = fmt.Sprintf("%q", buf)
-	if len(buf) > 40 {
-		buf = buf[0:18] + `"..."` + buf[len(buf)-19:]
+// write continues the open line of the same thread, or starts a new line
+// prefixed with the thread ID
+func (w *writer) write(tid int, str string) {
+	prefix := ""
+	if w.open != tid {
+		if w.open != 0 {
+			prefix = "\n"
+		}
+		prefix += fmt.Sprintf("[pid %d] ", tid)
+		prefix += w.options.timestamp(w.provider.Time(), &w.last)
+	}
+	w.open = 0
+	if !strings.HasSuffix(str, "\n") {
+		w.open = tid
 	}
-	return buf
+	_, _ = io.WriteString(w.out, prefix+str)
 }
@@ -0,0 +1,30 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start.Add(1500 * time.Millisecond)
+	for expected, options := range map[string]WriterOptions{
+		"":                               {},
+		"12:00:01 ":                      {Time: TimeOfDay},
+		"12:00:01.500000 ":               {Time: TimeOfDayMicros},
+		"1714564801.500000 ":             {Time: UnixMicros},
+		"     1.500000 ":                 {Relative: true},
+		"12:00:01.500000      1.500000 ": {Time: TimeOfDayMicros, Relative: true},
+	} {
+		last := start
+		if str := options.timestamp(now.In(time.UTC), &last); str != expected {
+			t.Errorf("expected %q, but got %q", expected, str)
+		}
+		if last != now {
+			t.Errorf("expected last to be updated")
+		}
+	}
+	if str := formatDuration(1500 * time.Microsecond); str != " <0.001500>" {
+		t.Errorf(`expected " <0.001500>", but got %q`, str)
+	}
+}
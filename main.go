@@ -1,162 +1,297 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"runtime"
+	"os/signal"
 	"strace/interceptor"
-	"strace/syscalls"
+	"strace/tracer"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 )
 
+const usageLine = "Usage: %s [-c [-S column] [-per-process] | -json | -compat [-y]] [-f] [-t | -tt | -ttt] [-r] [-T]\n" +
+	"          [-e expr]... [-P path]... [-o file [-ff] | -o '|command'] [-s strsize]\n" +
+	"          [-redirect from=to]... [-tamper file] [-seed n] [-proxy glob=url]... [-proxy-manifest file]\n" +
+	"          [-on-error policy] { -p pid | [--] command [args...] }\n"
+
 func main() {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
 	stderr := os.Stderr
-	_, _ = stderr.WriteString(fmt.Sprintf("Run %v\n", os.Args[1:]))
-
-	cmd := exec.Command(os.Args[1], os.Args[2:]...)
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Ptrace: true,
-	}
 
-	err := cmd.Start()
-	if err != nil {
-		panic(fmt.Sprintf("cmd start: %v", err))
+	var pids pidList
+	var expr interceptor.Expr
+	var proxies []interceptor.ProxyMapping
+	var redirects []interceptor.Mapping
+	policy := tracer.PolicyAbort
+	flag.Var(&pids, "p", "attach to running process `pid` (comma separated or repeated)")
+	follow := flag.Bool("f", false, "trace child processes, and all threads of processes given with -p")
+	output := flag.String("o", "", "write the trace to `file`, or to the standard input of |command, instead of stderr")
+	perPid := flag.Bool("ff", false, "with -o, write the trace of each process to file.<pid>, implies -f")
+	flag.Var(&expr, "e", "qualifying `expr`ession: [trace=][!]set, status=[!]set, trace-fds=[!]set\n"+
+		"or inject=set:error=errno|retval=n|delay_enter=d|delay_exit=d[:when=expr][:probability=p][:path=file][:fd=n]\n"+
+		"(repeatable)")
+	flag.Func("redirect", "make tracees see file or directory `to` where they ask for from, as from=to (repeatable)", func(value string) error {
+		m, err := interceptor.ParseMapping(value)
+		redirects = append(redirects, m)
+		return err
+	})
+	tamper := flag.String("tamper", "", "change arguments and return values of syscalls as the rules in `file` tell")
+	seed := flag.Int64("seed", 0, "seed probabilities and delays of inject and -tamper with `n`, for reproducible runs (default random)")
+	flag.Func("P", "trace only syscalls accessing `path` (repeatable)", expr.AddPath)
+	stringLimit := flag.Int("s", 32, "print at most `strsize` bytes of buffers")
+	timeOfDay := flag.Bool("t", false, "prefix each line with the time of day")
+	timeOfDayMicros := flag.Bool("tt", false, "prefix each line with the time of day, with microseconds")
+	unixMicros := flag.Bool("ttt", false, "prefix each line with the seconds since the epoch, with microseconds")
+	relative := flag.Bool("r", false, "prefix each line with the time since the previous line started")
+	duration := flag.Bool("T", false, "show the time spent in each syscall")
+	count := flag.Bool("c", false, "count calls, errors and time per syscall and print a summary instead of the trace")
+	var sortBy interceptor.SortBy
+	flag.Var(&sortBy, "S", "with -c, sort by `column`: time (default), avg-time, max-time, calls, errors, name or nothing")
+	perProcess := flag.Bool("per-process", false, "with -c, print a summary per process before that of all")
+	jsonLines := flag.Bool("json", false, "write the trace as JSON Lines, one object per syscall")
+	compat := flag.Bool("compat", false, "write the trace formatted like GNU strace, with signals and exits")
+	fdPaths := flag.Bool("y", false, "with -compat, print paths of file descriptors")
+	flag.Func("proxy", "serve reads of files matching a glob with HTTP range requests, as `glob=url`, where {name}\n"+
+		"and {path} in url are replaced by those of the file (repeatable)", func(value string) error {
+		m, err := interceptor.ParseProxyMapping(value)
+		proxies = append(proxies, m)
+		return err
+	})
+	flag.Func("proxy-manifest", "read -proxy mappings from `file`, one a line as glob and url", func(value string) error {
+		mappings, err := readProxyManifest(value)
+		proxies = append(proxies, mappings...)
+		return err
+	})
+	flag.Var(&policy, "on-error", "when tracing fails: `abort` (kill started, detach attached processes), detach or continue")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), usageLine, os.Args[0])
+		flag.PrintDefaults()
 	}
-	err = cmd.Wait() // cmd is paused
-	if err != nil {
-		var e *exec.ExitError
-		if !errors.As(err, &e) || e.ProcessState.Sys().(syscall.WaitStatus).StopSignal() != syscall.SIGTRAP {
-			// expected "stop signal: trace/breakpoint trap" (5)
-			panic(fmt.Sprintf("expected trap: %v", err))
-		}
+	flag.Parse()
+	switch {
+	case len(pids) == 0 && flag.NArg() == 0:
+		usageError("must have a command or -p pid")
+	case len(pids) > 0 && flag.NArg() > 0:
+		usageError("-p pid and a command are mutually exclusive")
+	case *count && *jsonLines, *count && *compat, *jsonLines && *compat:
+		usageError("-c, -json and -compat are mutually exclusive")
+	case *perPid && (*output == "" || strings.HasPrefix(*output, "|") || strings.HasPrefix(*output, "!")):
+		usageError("-ff needs -o file")
+	case *perPid && *count:
+		usageError("-c and -ff are mutually exclusive")
+	case *stringLimit < 0:
+		usageError("invalid -s strsize %d", *stringLimit)
 	}
 
-	var regs syscall.PtraceRegs
-	pid := cmd.Process.Pid
-	exit := true
-
-	fileDescriptor := make(map[int]string)
-
-	pro := provider{
-		pid:            pid,
-		fileDescriptor: fileDescriptor,
-	}
-	interceptors := []interceptor.Interceptor{
-		interceptor.Writer(&pro),
-		interceptor.Proxy(os.Getenv("FILE"), os.Getenv("URL"), &pro),
+	timestamps := interceptor.NoTimestamps
+	switch {
+	case *unixMicros:
+		timestamps = interceptor.UnixMicros
+	case *timeOfDayMicros:
+		timestamps = interceptor.TimeOfDayMicros
+	case *timeOfDay:
+		timestamps = interceptor.TimeOfDay
 	}
 
-program:
-	for {
-		if err = syscall.PtraceGetRegs(pid, &regs); err != nil {
-			panic(fmt.Sprintf("get regs (pid %d) err: %v\n", pid, err))
+	var out io.Writer
+	var closer io.Closer
+	files := newOutputs(*output)
+	if *perPid {
+		closer = files
+	} else {
+		o, err := openOutput(*output)
+		if err != nil {
+			_, _ = stderr.WriteString(fmt.Sprintf("%v\n", err))
+			os.Exit(1)
 		}
+		out, closer = o, o
+	}
 
-		r := syscalls.MapRegs(regs)
-
-		syscallNum := r.SyscallNum
-
-		arg1 := r.Arg1
-		arg2 := r.Arg2
-		arg3 := r.Arg3
-		arg4 := r.Arg4
-		arg5 := r.Arg5
-		arg6 := r.Arg6
+	t := tracer.New()
+	t.Follow = *follow || *perPid
+	t.Policy = policy
+	if !*compat || policy == tracer.PolicyContinue {
+		t.Log = stderr // strace reports exits itself
+	}
 
-		if !exit {
-			for _, inter := range interceptors {
-				inter.Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6)
-			}
-		} else {
-			retVal := r.RetVal
-			for _, inter := range interceptors {
-				inter.After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal)
-			}
+	pro := t.Provider()
+	pidPrefix := interceptor.PidsWhenMany
+	if *perPid {
+		pidPrefix = interceptor.NoPids
+	} else if *follow && *output != "" {
+		pidPrefix = interceptor.PidColumn
+	}
+	newShow := func(out io.Writer) interceptor.Interceptor {
+		switch {
+		case *compat:
+			return interceptor.Strace(pro, out, interceptor.StraceOptions{
+				WriterOptions: interceptor.WriterOptions{
+					StringLimit: *stringLimit,
+					Time:        timestamps,
+					Relative:    *relative,
+					Duration:    *duration,
+				},
+				FdPaths: *fdPaths,
+				Pids:    pidPrefix,
+			})
+		case *jsonLines:
+			return interceptor.JSONLines(pro, out, interceptor.WriterOptions{StringLimit: *stringLimit})
 		}
+		return interceptor.Writer(pro, out, interceptor.WriterOptions{
+			StringLimit: *stringLimit,
+			Time:        timestamps,
+			Relative:    *relative,
+			Duration:    *duration,
+		})
+	}
 
-		for {
-			err = syscall.PtraceSyscall(pid, 0) // wait for next syscall to begin or exit
+	var summary interceptor.Summarizer
+	var show interceptor.Interceptor
+	switch {
+	case *count:
+		summary = interceptor.Summary(pro, interceptor.SummaryOptions{SortBy: sortBy, PerProcess: *perProcess})
+		show = summary
+	case *perPid:
+		show = interceptor.PerProcess(pro, func(pid int) (interceptor.Interceptor, error) {
+			file, err := files.open(pid)
 			if err != nil {
-				panic(fmt.Sprintf("ptrace err: %v", err))
+				return nil, err
 			}
+			return newShow(file), nil
+		}, files.close)
+	default:
+		show = newShow(out)
+	}
+	if !expr.SelectsAll() {
+		show = interceptor.Filter(pro, expr, show)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	if len(expr.Faults) > 0 {
+		t.Register(interceptor.Inject(pro, expr.Faults, *seed))
+	}
+	if *tamper != "" {
+		rules, err := readRules(*tamper)
+		if err != nil {
+			_, _ = stderr.WriteString(fmt.Sprintf("tamper: %v\n", err))
+			os.Exit(1)
+		}
+		t.Register(interceptor.Tamper(pro, rules, *seed))
+	}
+	if len(redirects) > 0 {
+		t.Register(interceptor.Redirect(pro, redirects))
+	}
+	if len(proxies) > 0 {
+		// before show, which then sees the data it fills in
+		proxy, err := interceptor.Proxy(pro, proxies)
+		if err != nil {
+			_, _ = stderr.WriteString(fmt.Sprintf("proxy: %v\n", err))
+			os.Exit(1)
+		}
+		closer = closers{closer, proxy}
+		t.Register(proxy)
+	}
+	t.Register(show)
 
-			// wait for process to change state
-			var wstatus syscall.WaitStatus
-			_, err := syscall.Wait4(pid, &wstatus, 0, nil)
-			if err != nil {
-				panic(fmt.Sprintf("wait4 err: %v", err))
-			}
-			if wstatus.Exited() {
-				_, _ = stderr.WriteString(fmt.Sprintf(
-					"target process exited with code %d\n", wstatus.ExitStatus()))
-				break program
-			}
-			if wstatus.TrapCause() > -1 {
-				break
+	// stop tracing, rather than being killed with the output buffered
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	var cmd *exec.Cmd
+	if len(pids) > 0 {
+		_, _ = stderr.WriteString(fmt.Sprintf("Attach %v\n", []int(pids)))
+		for _, pid := range pids {
+			if err := t.Attach(pid); err != nil {
+				_, _ = stderr.WriteString(fmt.Sprintf("attach: %v\n", err))
+				cancel() // detach from the others
+				_ = t.Run(ctx)
+				_ = closer.Close()
+				os.Exit(1)
 			}
-			_, _ = stderr.WriteString(fmt.Sprintf("wstatus: %t %t %t %t %d\n",
-				wstatus.Continued(), wstatus.Stopped(), wstatus.Signaled(), wstatus.Exited(), wstatus.TrapCause()))
 		}
-		exit = !exit
+	} else {
+		if !*compat {
+			_, _ = stderr.WriteString(fmt.Sprintf("Run %v\n", flag.Args()))
+		}
+		cmd = exec.Command(flag.Arg(0), flag.Args()[1:]...)
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		if err := t.Start(cmd); err != nil {
+			_, _ = stderr.WriteString(fmt.Sprintf("%v\n", err))
+			_ = closer.Close()
+			os.Exit(1)
+		}
 	}
-}
-
-type provider struct {
-	pid            int
-	fileDescriptor map[int]string
-}
 
-func (p *provider) PutFileDescriptor(fd int, path string) {
-	p.fileDescriptor[fd] = path
+	err := t.Run(ctx)
+	if summary != nil {
+		_ = summary.Print(out)
+	}
+	if err := closer.Close(); err != nil {
+		_, _ = stderr.WriteString(fmt.Sprintf("closing: %v\n", err))
+	}
+	if errors.Is(err, context.Canceled) && cmd != nil {
+		// detached, and ended as strace ends the programs it started. Not
+		// with cmd.Process.Kill, which refuses as Start has waited for it.
+		_ = syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		os.Exit(1)
+	} else if errors.Is(err, context.Canceled) {
+		// attached processes are left running
+		_, _ = stderr.WriteString(fmt.Sprintf("Detached %v\n", []int(pids)))
+	} else if err != nil {
+		_, _ = stderr.WriteString(fmt.Sprintf("error: %v\n", err))
+		os.Exit(1)
+	}
 }
 
-func (p *provider) ReadPtraceText(addr uintptr) string {
-	return readPtraceText(p.pid, addr)
+func usageError(format string, a ...any) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], fmt.Sprintf(format, a...))
+	_, _ = fmt.Fprintf(os.Stderr, usageLine, os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "Try '%s -h' for more information.\n", os.Args[0])
+	os.Exit(2)
 }
 
-func (p *provider) ReadPtraceTextBuf(addr uintptr, size int) string {
-	return readPtraceTextBuf(p.pid, addr, size)
-}
+type pidList []int
 
-func (p *provider) FileName(fd int) string {
-	f, _ := p.fileDescriptor[fd]
-	return f
+func (p *pidList) String() string {
+	return fmt.Sprint(*p)
 }
 
-func (p *provider) FileDescriptor(filename string) int {
-	for fd, name := range p.fileDescriptor {
-		if name == filename {
-			return fd
+func (p *pidList) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		pid, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || pid < 1 {
+			return fmt.Errorf(`invalid pid "%s"`, s)
 		}
+		*p = append(*p, pid)
 	}
-	return -1
+	return nil
 }
 
-func readPtraceText(pid int, addr uintptr) string {
-	s := ""
-	buf := []byte{1}
-	for i := addr; ; i++ {
-		if c, err := syscall.PtracePeekText(pid, i, buf); err != nil {
-			panic(fmt.Sprintf("ptrace peek i: %v", err))
-		} else if c == 0 || buf[0] == 0 {
-			break
-		}
-		s += string(buf)
+// readProxyManifest reads the mappings of -proxy-manifest
+func readProxyManifest(filename string) ([]interceptor.ProxyMapping, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
-	return s
+	defer file.Close()
+	return interceptor.ReadProxyManifest(file)
 }
 
-func readPtraceTextBuf(pid int, addr uintptr, length int) string {
-	buf := make([]byte, length)
-	if _, err := syscall.PtracePeekText(pid, addr, buf); err != nil {
-		panic(fmt.Sprintf("ptrace peek buf: %v", err))
+// readRules reads the rules of -tamper
+func readRules(filename string) ([]interceptor.Rule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
-	return string(buf)
+	defer file.Close()
+	return interceptor.ParseRules(file)
 }
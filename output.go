@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// output is where the trace is written: stderr, a file, or the standard
+// input of a command given as "|cmd". Files and commands get buffered
+// writes, stderr is written line by line as strace does.
+type output struct {
+	io.Writer
+	buf    *bufio.Writer
+	closer io.Closer
+	cmd    *exec.Cmd
+}
+
+const outputBufferSize = 64 * 1024
+
+// openOutput opens the output named like with -o, where "" is stderr
+func openOutput(name string) (*output, error) {
+	switch {
+	case name == "":
+		return &output{Writer: os.Stderr}, nil
+	case strings.HasPrefix(name, "|"), strings.HasPrefix(name, "!"):
+		cmd := exec.Command("/bin/sh", "-c", name[1:])
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("starting %s: %w", name[1:], err)
+		}
+		buf := bufio.NewWriterSize(stdin, outputBufferSize)
+		return &output{buf, buf, stdin, cmd}, nil
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(file, outputBufferSize)
+	return &output{buf, buf, file, nil}, nil
+}
+
+// Close flushes the output, and waits for its command to finish
+func (o *output) Close() error {
+	var errs []error
+	if o.buf != nil {
+		errs = append(errs, o.buf.Flush())
+	}
+	if o.closer != nil {
+		errs = append(errs, o.closer.Close())
+	}
+	// ECHILD: it ended while tracing, and the tracer, waiting for any child,
+	// reaped it
+	if o.cmd != nil {
+		if err := o.cmd.Wait(); !errors.Is(err, syscall.ECHILD) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outputs are the files of -ff, named like trace.log.<pid>, open while
+// their process lives
+type outputs struct {
+	name  string
+	files map[int]*output // by pid
+	errs  []error         // of closing the files of processes gone
+}
+
+func newOutputs(name string) *outputs {
+	return &outputs{name: name, files: map[int]*output{}}
+}
+
+func (o *outputs) open(pid int) (*output, error) {
+	out, err := openOutput(fmt.Sprintf("%s.%d", o.name, pid))
+	if err != nil {
+		return nil, err
+	}
+	o.files[pid] = out
+	return out, nil
+}
+
+// close closes the file of a process gone, as strace does, so tracing many
+// processes does not run out of fds
+func (o *outputs) close(pid int) {
+	if out, ok := o.files[pid]; ok {
+		o.errs = append(o.errs, out.Close())
+		delete(o.files, pid)
+	}
+}
+
+func (o *outputs) Close() error {
+	for pid := range o.files {
+		o.close(pid)
+	}
+	return errors.Join(o.errs...)
+}
+
+// closers are closed together, like the output and the files of -proxy
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strace/interceptor"
+	"strace/tracer/tracertest"
+	"testing"
+)
+
+func TestOutputFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	out, err := openOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = out.Write([]byte("read(3) = 5\n"))
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "read(3) = 5\n" {
+		t.Errorf(`expected the trace in the file, but got %q`, data)
+	}
+}
+
+func TestOutputCommand(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	out, err := openOutput("|tr a-z A-Z >" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tracer waits for any child meanwhile
+	tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return interceptor.Writer(p, out, interceptor.WriterOptions{})
+	})
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); !bytes.Contains(data, []byte(`"HELLO"`)) {
+		t.Errorf("expected the trace in upper case, but got\n%s", data)
+	}
+
+	// reaped by the tracer, as it ends while tracing
+	if out, err = openOutput("|true"); err != nil {
+		t.Fatal(err)
+	}
+	tracertest.RunCmd(t, exec.Command("sleep", "0.1"), func(p interceptor.Provider) interceptor.Interceptor {
+		return interceptor.Writer(p, io.Discard, interceptor.WriterOptions{})
+	})
+	if err := out.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	files := newOutputs(name)
+	for _, pid := range []int{1, 2} {
+		out, err := files.open(pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = out.Write([]byte("exit_group(0) = ?\n"))
+	}
+	files.close(1)
+	if data, _ := os.ReadFile(name + ".1"); string(data) != "exit_group(0) = ?\n" {
+		t.Errorf("expected the file of a process gone to be written, but got %q", data)
+	}
+	if len(files.files) != 1 {
+		t.Errorf("expected the file of the process left open, but got %v", files.files)
+	}
+	if err := files.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name + ".2"); string(data) != "exit_group(0) = ?\n" {
+		t.Errorf("expected the file of the process left to be written, but got %q", data)
+	}
+}
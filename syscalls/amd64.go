@@ -28,3 +28,12 @@ func init() {
 		}
 	}
 }
+
+// newer than the syscall package
+func init() {
+	syscallNames[318] = "GETRANDOM"
+	syscallNames[319] = "MEMFD_CREATE"
+	syscallNames[326] = "COPY_FILE_RANGE"
+	syscallNames[332] = "STATX"
+	syscallNames[334] = "RSEQ"
+}
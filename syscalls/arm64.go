@@ -35,3 +35,12 @@ func init() {
 		}
 	}
 }
+
+// newer than the syscall package
+func init() {
+	syscallNames[278] = "GETRANDOM"
+	syscallNames[279] = "MEMFD_CREATE"
+	syscallNames[285] = "COPY_FILE_RANGE"
+	syscallNames[291] = "STATX"
+	syscallNames[293] = "RSEQ"
+}
@@ -0,0 +1,34 @@
+package syscalls
+
+import "slices"
+
+// syscallClasses holds the names of the syscalls of each class, like
+// "file" for %file, generated from classes.txt
+var syscallClasses = map[string][]string{}
+
+// GetClass returns the numbers of the syscalls of a class that exist on
+// this architecture
+func GetClass(class string) ([]int, bool) {
+	names, ok := syscallClasses[class]
+	if !ok {
+		return nil, false
+	}
+	var nums []int
+	for _, name := range names {
+		if num, ok := GetNum(name); ok {
+			nums = append(nums, num)
+		}
+	}
+	slices.Sort(nums)
+	return nums, true
+}
+
+// Nums returns the numbers of all syscalls known by name
+func Nums() []int {
+	nums := make([]int, 0, len(syscallNames))
+	for num := range syscallNames {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+	return nums
+}
@@ -0,0 +1,103 @@
+package syscalls
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// IsError tells whether a syscall return value is -errno
+func IsError(retVal int) bool {
+	return -4096 < retVal && retVal < 0
+}
+
+// IsInterrupted tells whether a syscall return value is EINTR, or one of
+// the errors of syscalls interrupted by a signal that the kernel restarts
+func IsInterrupted(retVal int) bool {
+	_, restart := kernelMessages[-retVal]
+	return retVal == -int(syscall.EINTR) || restart
+}
+
+// GetErrnoName returns the symbolic name of an error number, like ENOENT
+func GetErrnoName(errno int) string {
+	if name, ok := errnoNames[errno]; ok {
+		return name
+	}
+	return fmt.Sprintf("errno %d", errno)
+}
+
+// GetErrno returns the error number of a symbolic name, like ENOENT, or an
+// alias, like EWOULDBLOCK
+func GetErrno(name string) (int, bool) {
+	if errno, ok := errnoAliases[name]; ok {
+		return errno, true
+	}
+	for errno, n := range errnoNames {
+		if n == name {
+			return errno, true
+		}
+	}
+	return 0, false
+}
+
+// GetErrnoMessage returns the description of an error number, like
+// "No such file or directory"
+func GetErrnoMessage(errno int) string {
+	if message, ok := kernelMessages[errno]; ok {
+		return message
+	}
+	message := syscall.Errno(errno).Error()
+	if message == "" {
+		return "Unknown error"
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// FormatError formats -errno like strace: -1 ENOENT (No such file or directory)
+func FormatError(retVal int) string {
+	errno := -retVal
+	if _, ok := kernelMessages[errno]; ok {
+		// never seen by the process, which is restarted or gets EINTR
+		return fmt.Sprintf("? %s (%s)", GetErrnoName(errno), GetErrnoMessage(errno))
+	}
+	return fmt.Sprintf("-1 %s (%s)", GetErrnoName(errno), GetErrnoMessage(errno))
+}
+
+// common UNIX error numbers, the rest are generated
+var errnoNames = map[int]string{
+	int(syscall.EPERM):   "EPERM",
+	int(syscall.ENOENT):  "ENOENT",
+	int(syscall.ESRCH):   "ESRCH",
+	int(syscall.EINTR):   "EINTR",
+	int(syscall.EIO):     "EIO",
+	int(syscall.EBADF):   "EBADF",
+	int(syscall.ECHILD):  "ECHILD",
+	int(syscall.EAGAIN):  "EAGAIN",
+	int(syscall.ENOMEM):  "ENOMEM",
+	int(syscall.EACCES):  "EACCES",
+	int(syscall.EFAULT):  "EFAULT",
+	int(syscall.EEXIST):  "EEXIST",
+	int(syscall.ENOTDIR): "ENOTDIR",
+	int(syscall.EISDIR):  "EISDIR",
+	int(syscall.EINVAL):  "EINVAL",
+	int(syscall.ENOSPC):  "ENOSPC",
+	int(syscall.ESPIPE):  "ESPIPE",
+	int(syscall.EPIPE):   "EPIPE",
+	int(syscall.ERANGE):  "ERANGE",
+
+	// Linux kernel internal, seen by tracers at syscall exit
+	512: "ERESTARTSYS",
+	513: "ERESTARTNOINTR",
+	514: "ERESTARTNOHAND",
+	516: "ERESTART_RESTARTBLOCK",
+}
+
+// other names of error numbers, like EWOULDBLOCK for EAGAIN, generated
+var errnoAliases = map[string]int{}
+
+var kernelMessages = map[int]string{
+	512: "To be restarted if SA_RESTART is set",
+	513: "To be restarted",
+	514: "To be restarted if no handler",
+	516: "Interrupted by signal",
+}
@@ -0,0 +1,201 @@
+package syscalls
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// AT_FDCWD as dirfd resolves paths relative to the working directory
+const AT_FDCWD = -100
+
+// flagValues by name; generated from the syscall package, plus those
+// it does not export, which are the same on all Linux architectures
+var flagValues = map[string]int{
+	"AT_SYMLINK_NOFOLLOW":      0x100,
+	"AT_REMOVEDIR":             0x200,
+	"AT_SYMLINK_FOLLOW":        0x400,
+	"AT_NO_AUTOMOUNT":          0x800,
+	"AT_EMPTY_PATH":            0x1000,
+	"SEEK_SET":                 0,
+	"SEEK_CUR":                 1,
+	"SEEK_END":                 2,
+	"SEEK_DATA":                3,
+	"SEEK_HOLE":                4,
+	"POLLIN":                   0x1,
+	"POLLPRI":                  0x2,
+	"POLLOUT":                  0x4,
+	"POLLERR":                  0x8,
+	"POLLHUP":                  0x10,
+	"POLLNVAL":                 0x20,
+	"POLLRDNORM":               0x40,
+	"POLLRDBAND":               0x80,
+	"POLLWRNORM":               0x100,
+	"POLLWRBAND":               0x200,
+	"POLLRDHUP":                0x2000,
+	"F_OK":                     0,
+	"X_OK":                     1,
+	"W_OK":                     2,
+	"R_OK":                     4,
+	"TIMER_ABSTIME":            1,
+	"SIG_BLOCK":                0,
+	"SIG_UNBLOCK":              1,
+	"SIG_SETMASK":              2,
+	"CLOCK_REALTIME":           0,
+	"CLOCK_MONOTONIC":          1,
+	"CLOCK_PROCESS_CPUTIME_ID": 2,
+	"CLOCK_THREAD_CPUTIME_ID":  3,
+	"CLOCK_MONOTONIC_RAW":      4,
+	"CLOCK_REALTIME_COARSE":    5,
+	"CLOCK_MONOTONIC_COARSE":   6,
+	"CLOCK_BOOTTIME":           7,
+	"CLOCK_REALTIME_ALARM":     8,
+	"CLOCK_BOOTTIME_ALARM":     9,
+	"CLOCK_TAI":                11,
+	// those of the syscall package are generated, these differ on mips,
+	// sparc and alpha but not on amd64 and arm64
+	"RLIMIT_RSS":        5,
+	"RLIMIT_NPROC":      6,
+	"RLIMIT_MEMLOCK":    8,
+	"RLIMIT_LOCKS":      10,
+	"RLIMIT_SIGPENDING": 11,
+	"RLIMIT_MSGQUEUE":   12,
+	"RLIMIT_NICE":       13,
+	"RLIMIT_RTPRIO":     14,
+	"RLIMIT_RTTIME":     15,
+}
+
+// aliases and masks that are not printed
+var flagAliases = []string{
+	"AF_FILE", "AF_LOCAL", "AF_ROUTE",
+	"MAP_ANON", "MAP_FILE", "MAP_TYPE",
+	"MSG_TRYHARD",
+	"O_ACCMODE", "O_FSYNC", "O_NDELAY", "O_RSYNC",
+	"O_LARGEFILE", // 0 in the syscall package of 64-bit architectures
+}
+
+type flagSet struct {
+	prefix string
+	suffix string // of the names, if not only by prefix
+	enum   int    // mask of bits that hold a value rather than flags
+	isEnum bool   // the whole argument is a value
+	noZero bool   // no flags is 0, not the name of the value 0
+	desc   bool   // printed by decreasing value, like R_OK|W_OK
+}
+
+var flagSets = map[ArgKind]flagSet{
+	OpenFlags:  {prefix: "O_", enum: 3},
+	FdFlags:    {prefix: "O_", noZero: true},
+	AtFlags:    {prefix: "AT_"},
+	ProtFlags:  {prefix: "PROT_"},
+	MapFlags:   {prefix: "MAP_"},
+	MsgFlags:   {prefix: "MSG_"},
+	Whence:     {prefix: "SEEK_", isEnum: true},
+	PollEvents: {prefix: "POLL"},
+	Family:     {prefix: "AF_", isEnum: true},
+	AccessMode: {suffix: "_OK", desc: true},
+	CloneFlags: {prefix: "CLONE_", noZero: true},
+	Resource:   {prefix: "RLIMIT_", isEnum: true},
+	ClockId:    {prefix: "CLOCK_", isEnum: true},
+	TimerFlags: {prefix: "TIMER_"},
+	SigHow:     {prefix: "SIG_", isEnum: true},
+}
+
+type flag struct {
+	name  string
+	value int
+}
+
+// flags returns the flags of a set, those of most bits first
+func flags(set flagSet) []flag {
+	return sortedFlags()[set]
+}
+
+var sortedFlags = sync.OnceValue(func() map[flagSet][]flag {
+	sorted := map[flagSet][]flag{}
+	for _, set := range flagSets {
+		var f []flag
+		for name, value := range flagValues {
+			if strings.HasPrefix(name, set.prefix) && strings.HasSuffix(name, set.suffix) &&
+				!slices.Contains(flagAliases, name) {
+				f = append(f, flag{name, value})
+			}
+		}
+		sorted[set] = f
+	}
+	for _, f := range sorted {
+		slices.SortFunc(f, func(a, b flag) int {
+			if n := bits.OnesCount(uint(b.value)) - bits.OnesCount(uint(a.value)); n != 0 {
+				return n
+			}
+			return strings.Compare(a.name, b.name)
+		})
+	}
+	return sorted
+})
+
+// GetFlag returns the value of a flag or enum by name, like O_CREAT
+func GetFlag(name string) (int, bool) {
+	value, ok := flagValues[name]
+	return value, ok
+}
+
+// FormatFlags formats a flag or enum argument symbolically, like
+// O_RDONLY|O_CLOEXEC, with unknown bits in hex
+func FormatFlags(kind ArgKind, value int) string {
+	set, ok := flagSets[kind]
+	if !ok && value == 0 {
+		return "0"
+	} else if !ok {
+		return fmt.Sprintf("%#x", value)
+	}
+	value = int(uint32(value))
+	if set.isEnum {
+		return enumName(set, value)
+	}
+
+	var found []flag
+	if set.enum != 0 {
+		found = append(found, flag{enumName(set, value&set.enum), 0})
+		value &^= set.enum
+	}
+	for _, f := range flags(set) {
+		if f.value > 0 && f.value&set.enum == 0 && value&f.value == f.value {
+			found = append(found, f)
+			value &^= f.value
+		}
+	}
+	slices.SortStableFunc(found, func(a, b flag) int { return a.value - b.value })
+	if set.desc {
+		slices.Reverse(found)
+	}
+
+	var strs []string
+	for _, f := range found {
+		strs = append(strs, f.name)
+	}
+	if value != 0 {
+		strs = append(strs, fmt.Sprintf("%#x", value))
+	}
+	if len(strs) == 0 && set.noZero {
+		return "0"
+	} else if len(strs) == 0 {
+		return enumName(set, 0) // like PROT_NONE
+	}
+	return strings.Join(strs, "|")
+}
+
+// enumName returns the name of a value, or the value in hex
+func enumName(set flagSet, value int) string {
+	for _, f := range flags(set) {
+		if f.value == value {
+			return f.name
+		}
+	}
+	if value == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%#x", value)
+}
@@ -0,0 +1,92 @@
+package syscalls
+
+import (
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+)
+
+// Op tells what kind of syscall-stop a thread is in
+type Op uint8
+
+const (
+	OpNone Op = iota
+	OpEntry
+	OpExit
+	OpSeccomp
+)
+
+// Info is the result of PTRACE_GET_SYSCALL_INFO (Linux 5.3+).
+// At entry Regs holds the syscall number and arguments, at exit RetVal.
+type Info struct {
+	Op                 Op
+	Arch               uint32 // AUDIT_ARCH_*
+	InstructionPointer uint64
+	StackPointer       uint64
+	Regs
+	IsError bool
+}
+
+const ptraceGetSyscallInfo = 0x420e
+
+// https://man7.org/linux/man-pages/man2/ptrace.2.html
+//
+//	struct ptrace_syscall_info {
+//	    __u8 op;        /* Type of system call stop */
+//	    __u8 pad[3];
+//	    __u32 arch;     /* AUDIT_ARCH_* value; see seccomp(2) */
+//	    __u64 instruction_pointer; /* CPU instruction pointer */
+//	    __u64 stack_pointer;    /* CPU stack pointer */
+//	    union {
+//	        struct {    /* op == PTRACE_SYSCALL_INFO_ENTRY */
+//	            __u64 nr;       /* System call number */
+//	            __u64 args[6];  /* System call arguments */
+//	        } entry;
+//	        struct {    /* op == PTRACE_SYSCALL_INFO_EXIT */
+//	            __s64 rval;     /* System call return value */
+//	            __u8 is_error;  /* System call error flag */
+//	        } exit;
+//	        struct {    /* op == PTRACE_SYSCALL_INFO_SECCOMP */
+//	            __u64 nr;       /* System call number */
+//	            __u64 args[6];  /* System call arguments */
+//	            __u32 ret_data; /* SECCOMP_RET_DATA portion of SECCOMP_RET_TRACE return value */
+//	        } seccomp;
+//	    };
+//	};
+type rawInfo [88]byte
+
+// GetInfo returns the syscall-stop state of a stopped thread. Kernels
+// without PTRACE_GET_SYSCALL_INFO return EIO.
+func GetInfo(tid int) (Info, error) {
+	var buf rawInfo
+	_, _, e := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSyscallInfo,
+		uintptr(tid), unsafe.Sizeof(buf), uintptr(unsafe.Pointer(&buf)), 0, 0)
+	if e != 0 {
+		return Info{}, e
+	}
+	u64 := func(offset int) int {
+		return int(binary.NativeEndian.Uint64(buf[offset:]))
+	}
+	info := Info{
+		Op:                 Op(buf[0]),
+		Arch:               binary.NativeEndian.Uint32(buf[4:]),
+		InstructionPointer: uint64(u64(8)),
+		StackPointer:       uint64(u64(16)),
+	}
+	switch info.Op {
+	case OpEntry, OpSeccomp:
+		info.Regs = Regs{
+			SyscallNum: u64(24),
+			Arg1:       u64(32),
+			Arg2:       u64(40),
+			Arg3:       u64(48),
+			Arg4:       u64(56),
+			Arg5:       u64(64),
+			Arg6:       u64(72),
+		}
+	case OpExit:
+		info.RetVal = u64(24)
+		info.IsError = buf[32] != 0
+	}
+	return info, nil
+}
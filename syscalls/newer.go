@@ -0,0 +1,19 @@
+//go:build linux
+
+package syscalls
+
+// syscalls newer than the syscall package, numbered alike on all
+// architectures since Linux 5.1
+func init() {
+	syscallNames[424] = "PIDFD_SEND_SIGNAL"
+	syscallNames[425] = "IO_URING_SETUP"
+	syscallNames[426] = "IO_URING_ENTER"
+	syscallNames[427] = "IO_URING_REGISTER"
+	syscallNames[434] = "PIDFD_OPEN"
+	syscallNames[435] = "CLONE3"
+	syscallNames[436] = "CLOSE_RANGE"
+	syscallNames[437] = "OPENAT2"
+	syscallNames[438] = "PIDFD_GETFD"
+	syscallNames[439] = "FACCESSAT2"
+	syscallNames[441] = "EPOLL_PWAIT2"
+}
@@ -0,0 +1,65 @@
+package syscalls
+
+import (
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+)
+
+// Siginfo is the part of siginfo_t that tells who sent a signal, or why
+type Siginfo struct {
+	Signo syscall.Signal
+	Code  int
+	Pid   int // sender, or child of SIGCHLD
+	Uid   int
+	// SIGCHLD
+	Status       int // exit status, or signal
+	Utime, Stime int // in clock ticks
+	// SIGSEGV, SIGBUS, SIGILL, SIGFPE, SIGTRAP
+	Addr uint64
+}
+
+const ptraceGetSiginfo = 0x4202
+
+// https://man7.org/linux/man-pages/man2/sigaction.2.html
+//
+//	typedef struct {
+//	    int si_signo;
+//	    int si_errno;
+//	    int si_code;
+//	    union {
+//	        struct { pid_t si_pid; uid_t si_uid; } kill;
+//	        struct { pid_t si_pid; uid_t si_uid; int si_status;
+//	                 clock_t si_utime; clock_t si_stime; } sigchld;
+//	        struct { void *si_addr; ... } sigfault;
+//	        ...
+//	    };
+//	} siginfo_t;
+type rawSiginfo [128]byte
+
+// GetSiginfo returns the signal a thread is stopped by
+func GetSiginfo(tid int) (Siginfo, error) {
+	var buf rawSiginfo
+	_, _, e := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSiginfo,
+		uintptr(tid), 0, uintptr(unsafe.Pointer(&buf)), 0, 0)
+	if e != 0 {
+		return Siginfo{}, e
+	}
+	i32 := func(offset int) int {
+		return int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+	}
+	i64 := func(offset int) int {
+		return int(binary.NativeEndian.Uint64(buf[offset:]))
+	}
+	// the union is 8-byte aligned on 64-bit architectures
+	return Siginfo{
+		Signo:  syscall.Signal(i32(0)),
+		Code:   i32(8),
+		Pid:    i32(16),
+		Uid:    int(binary.NativeEndian.Uint32(buf[20:])),
+		Status: i32(24),
+		Utime:  i64(32),
+		Stime:  i64(40),
+		Addr:   binary.NativeEndian.Uint64(buf[16:]),
+	}, nil
+}
@@ -0,0 +1,256 @@
+package syscalls
+
+// ArgKind tells how to decode a syscall argument or return value
+type ArgKind int
+
+const (
+	Int          ArgKind = iota // C int, 32 bits
+	Long                        // long, off_t, ssize_t
+	Size                        // size_t, unsigned
+	Hex                         // address or opaque value
+	Fd                          // file descriptor
+	Dirfd                       // file descriptor for *at path resolution
+	Path                        // NUL-terminated file name, always printed in full
+	Str                         // NUL-terminated string
+	BufIn                       // buffer read by the kernel, length in next argument
+	BufOut                      // buffer written by the kernel, length in next argument
+	Mode                        // file mode, octal
+	Flags                       // bit flags, in hex
+	OpenFlags                   // O_* of open, access mode and flags
+	FdFlags                     // O_* of dup3, pipe2, flags only
+	AtFlags                     // AT_* of *at
+	ProtFlags                   // PROT_* of mmap, mprotect
+	MapFlags                    // MAP_* of mmap
+	MsgFlags                    // MSG_* of send, recv
+	Whence                      // SEEK_* of lseek
+	PollEvents                  // POLL* of struct pollfd
+	Family                      // AF_* of socket, struct sockaddr
+	AccessMode                  // F_OK or R_OK, W_OK and X_OK of access
+	CloneFlags                  // CLONE_* of clone, and the exit signal in the low byte
+	Resource                    // RLIMIT_* of getrlimit, setrlimit, prlimit64
+	ClockId                     // CLOCK_* of clock_gettime and the like
+	TimerFlags                  // TIMER_ABSTIME of clock_nanosleep
+	Signal                      // signal number, like SIGINT
+	SigHow                      // SIG_BLOCK and the like of rt_sigprocmask
+	Stat                        // struct stat, written by the kernel
+	Timespec                    // struct timespec
+	TimespecOut                 // struct timespec, written by the kernel
+	TimespecRem                 // struct timespec, written by the kernel when interrupted
+	Iovec                       // array of struct iovec, count in next argument
+	IovecOut                    // array of struct iovec read into, count in next argument
+	Sockaddr                    // struct sockaddr, length in next argument
+	Pollfd                      // array of struct pollfd, count in next argument
+	PipeFds                     // int[2] of pipe, written by the kernel
+	Sigset                      // sigset_t of the kernel, 64 signals
+	SigsetOut                   // sigset_t, written by the kernel
+	Sigaction                   // struct sigaction of the kernel
+	SigactionOut                // struct sigaction, written by the kernel
+	Rlimit                      // struct rlimit
+	RlimitOut                   // struct rlimit, written by the kernel
+	StrArray                    // NULL-terminated array of strings, like argv
+	Environ                     // NULL-terminated array of strings, only counted
+	NoReturn                    // return kind of syscalls that do not return
+)
+
+// Out tells whether the kernel writes the argument, so that it is decoded
+// at syscall exit
+func (k ArgKind) Out() bool {
+	switch k {
+	case BufOut, Stat, TimespecOut, TimespecRem, IovecOut, RlimitOut, PipeFds, SigsetOut, SigactionOut:
+		return true
+	}
+	return false
+}
+
+// Signature describes the arguments and return value of a syscall
+type Signature struct {
+	Args []ArgKind
+	Ret  ArgKind
+}
+
+// unknown is used for syscalls without a signature: all six registers
+var unknown = Signature{[]ArgKind{Hex, Hex, Hex, Hex, Hex, Hex}, Long}
+
+// GetSignature returns the signature of a syscall by number
+func GetSignature(syscallID int) Signature {
+	if sig, ok := signatures[GetName(syscallID)]; ok {
+		return sig
+	}
+	return unknown
+}
+
+func sig(ret ArgKind, args ...ArgKind) Signature {
+	return Signature{args, ret}
+}
+
+// signatures by name, as in syscallNames, from the man-pages(2)
+var signatures = map[string]Signature{
+	"ACCEPT":            sig(Fd, Fd, Hex, Hex),
+	"ACCEPT4":           sig(Fd, Fd, Hex, Hex, Flags),
+	"ACCESS":            sig(Int, Path, AccessMode),
+	"ALARM":             sig(Int, Int),
+	"ARCH_PRCTL":        sig(Int, Int, Hex),
+	"BIND":              sig(Int, Fd, Sockaddr, Int),
+	"BRK":               sig(Hex, Hex),
+	"CHDIR":             sig(Int, Path),
+	"CHMOD":             sig(Int, Path, Mode),
+	"CHOWN":             sig(Int, Path, Int, Int),
+	"CHROOT":            sig(Int, Path),
+	"CLOCK_GETRES":      sig(Int, ClockId, TimespecOut),
+	"CLOCK_GETTIME":     sig(Int, ClockId, TimespecOut),
+	"CLOCK_NANOSLEEP":   sig(Int, ClockId, TimerFlags, Timespec, TimespecRem),
+	"CLONE":             sig(Int, CloneFlags, Hex, Hex, Hex, Hex),
+	"CLONE3":            sig(Int, Hex, Size),
+	"CLOSE":             sig(Int, Fd),
+	"CLOSE_RANGE":       sig(Int, Fd, Fd, Flags),
+	"CONNECT":           sig(Int, Fd, Sockaddr, Int),
+	"COPY_FILE_RANGE":   sig(Long, Fd, Hex, Fd, Hex, Size, Flags),
+	"CREAT":             sig(Fd, Path, Mode),
+	"DUP":               sig(Fd, Fd),
+	"DUP2":              sig(Fd, Fd, Fd),
+	"DUP3":              sig(Fd, Fd, Fd, FdFlags),
+	"EPOLL_CREATE":      sig(Fd, Int),
+	"EPOLL_CREATE1":     sig(Fd, Flags),
+	"EPOLL_CTL":         sig(Int, Fd, Int, Fd, Hex),
+	"EPOLL_PWAIT":       sig(Int, Fd, Hex, Int, Int, Hex, Size),
+	"EPOLL_WAIT":        sig(Int, Fd, Hex, Int, Int),
+	"EVENTFD":           sig(Fd, Int),
+	"EVENTFD2":          sig(Fd, Int, Flags),
+	"EXECVE":            sig(Int, Path, StrArray, Environ),
+	"EXIT":              sig(NoReturn, Int),
+	"EXIT_GROUP":        sig(NoReturn, Int),
+	"FACCESSAT":         sig(Int, Dirfd, Path, AccessMode),
+	"FACCESSAT2":        sig(Int, Dirfd, Path, AccessMode, AtFlags),
+	"FADVISE64":         sig(Int, Fd, Long, Long, Int),
+	"FALLOCATE":         sig(Int, Fd, Int, Long, Long),
+	"FCHDIR":            sig(Int, Fd),
+	"FCHMOD":            sig(Int, Fd, Mode),
+	"FCHMODAT":          sig(Int, Dirfd, Path, Mode),
+	"FCHOWN":            sig(Int, Fd, Int, Int),
+	"FCHOWNAT":          sig(Int, Dirfd, Path, Int, Int, AtFlags),
+	"FCNTL":             sig(Long, Fd, Int, Hex),
+	"FDATASYNC":         sig(Int, Fd),
+	"FLOCK":             sig(Int, Fd, Int),
+	"FORK":              sig(Int),
+	"FSTAT":             sig(Int, Fd, Stat),
+	"FSTATFS":           sig(Int, Fd, Hex),
+	"FSYNC":             sig(Int, Fd),
+	"FTRUNCATE":         sig(Int, Fd, Long),
+	"FUTEX":             sig(Long, Hex, Int, Int, Hex, Hex, Int),
+	"GETCWD":            sig(Long, BufOut, Size),
+	"GETDENTS64":        sig(Long, Fd, Hex, Size),
+	"GETEGID":           sig(Int),
+	"GETEUID":           sig(Int),
+	"GETGID":            sig(Int),
+	"GETGROUPS":         sig(Int, Int, Hex),
+	"GETPEERNAME":       sig(Int, Fd, Hex, Hex),
+	"GETPGID":           sig(Int, Int),
+	"GETPGRP":           sig(Int),
+	"GETPID":            sig(Int),
+	"GETPPID":           sig(Int),
+	"GETPRIORITY":       sig(Int, Int, Int),
+	"GETRANDOM":         sig(Long, BufOut, Size, Flags),
+	"GETRESGID":         sig(Int, Hex, Hex, Hex),
+	"GETRESUID":         sig(Int, Hex, Hex, Hex),
+	"GETRLIMIT":         sig(Int, Resource, RlimitOut),
+	"GETRUSAGE":         sig(Int, Int, Hex),
+	"GETSID":            sig(Int, Int),
+	"GETSOCKNAME":       sig(Int, Fd, Hex, Hex),
+	"GETSOCKOPT":        sig(Int, Fd, Int, Int, Hex, Hex),
+	"GETTID":            sig(Int),
+	"GETTIMEOFDAY":      sig(Int, Hex, Hex),
+	"GETUID":            sig(Int),
+	"INOTIFY_ADD_WATCH": sig(Int, Fd, Path, Flags),
+	"INOTIFY_INIT1":     sig(Fd, Flags),
+	"IOCTL":             sig(Int, Fd, Hex, Hex),
+	"KILL":              sig(Int, Int, Signal),
+	"LCHOWN":            sig(Int, Path, Int, Int),
+	"LINK":              sig(Int, Path, Path),
+	"LINKAT":            sig(Int, Dirfd, Path, Dirfd, Path, AtFlags),
+	"LISTEN":            sig(Int, Fd, Int),
+	"LSEEK":             sig(Long, Fd, Long, Whence),
+	"LSTAT":             sig(Int, Path, Stat),
+	"MADVISE":           sig(Int, Hex, Size, Int),
+	"MEMFD_CREATE":      sig(Fd, Str, Flags),
+	"MKDIR":             sig(Int, Path, Mode),
+	"MKDIRAT":           sig(Int, Dirfd, Path, Mode),
+	"MKNOD":             sig(Int, Path, Mode, Hex),
+	"MKNODAT":           sig(Int, Dirfd, Path, Mode, Hex),
+	"MLOCK":             sig(Int, Hex, Size),
+	"MMAP":              sig(Hex, Hex, Size, ProtFlags, MapFlags, Fd, Long),
+	"MOUNT":             sig(Int, Str, Path, Str, Flags, Hex),
+	"MPROTECT":          sig(Int, Hex, Size, ProtFlags),
+	"MREMAP":            sig(Hex, Hex, Size, Size, Flags, Hex),
+	"MSYNC":             sig(Int, Hex, Size, Flags),
+	"MUNLOCK":           sig(Int, Hex, Size),
+	"MUNMAP":            sig(Int, Hex, Size),
+	"NANOSLEEP":         sig(Int, Timespec, TimespecRem),
+	"NEWFSTATAT":        sig(Int, Dirfd, Path, Stat, AtFlags),
+	"OPEN":              sig(Fd, Path, OpenFlags, Mode),
+	"OPENAT":            sig(Fd, Dirfd, Path, OpenFlags, Mode),
+	"OPENAT2":           sig(Fd, Dirfd, Path, Hex, Size),
+	"PAUSE":             sig(Int),
+	"PIDFD_OPEN":        sig(Fd, Int, Flags),
+	"PIPE":              sig(Int, PipeFds),
+	"PIPE2":             sig(Int, PipeFds, FdFlags),
+	"POLL":              sig(Int, Pollfd, Int, Int),
+	"PPOLL":             sig(Int, Pollfd, Int, Timespec, Hex, Size),
+	"PRCTL":             sig(Int, Int, Hex, Hex, Hex, Hex),
+	"PREAD64":           sig(Long, Fd, BufOut, Size, Long),
+	"PRLIMIT64":         sig(Int, Int, Resource, Rlimit, RlimitOut),
+	"PSELECT6":          sig(Int, Int, Hex, Hex, Hex, Hex, Hex),
+	"PWRITE64":          sig(Long, Fd, BufIn, Size, Long),
+	"READ":              sig(Long, Fd, BufOut, Size),
+	"READLINK":          sig(Long, Path, BufOut, Size),
+	"READLINKAT":        sig(Long, Dirfd, Path, BufOut, Size),
+	"READV":             sig(Long, Fd, IovecOut, Int),
+	"RECVFROM":          sig(Long, Fd, BufOut, Size, MsgFlags, Hex, Hex),
+	"RECVMSG":           sig(Long, Fd, Hex, MsgFlags),
+	"RENAME":            sig(Int, Path, Path),
+	"RENAMEAT":          sig(Int, Dirfd, Path, Dirfd, Path),
+	"RMDIR":             sig(Int, Path),
+	"RSEQ":              sig(Int, Hex, Size, Flags, Hex),
+	"RT_SIGACTION":      sig(Int, Signal, Sigaction, SigactionOut, Size),
+	"RT_SIGPROCMASK":    sig(Int, SigHow, Sigset, SigsetOut, Size),
+	"RT_SIGRETURN":      sig(Int),
+	"SCHED_GETAFFINITY": sig(Int, Int, Size, Hex),
+	"SCHED_YIELD":       sig(Int),
+	"SELECT":            sig(Int, Int, Hex, Hex, Hex, Hex),
+	"SENDFILE":          sig(Long, Fd, Fd, Hex, Size),
+	"SENDMSG":           sig(Long, Fd, Hex, MsgFlags),
+	"SENDTO":            sig(Long, Fd, BufIn, Size, MsgFlags, Sockaddr, Int),
+	"SETGID":            sig(Int, Int),
+	"SETPGID":           sig(Int, Int, Int),
+	"SETRLIMIT":         sig(Int, Resource, Rlimit),
+	"SETSID":            sig(Int),
+	"SETSOCKOPT":        sig(Int, Fd, Int, Int, Hex, Int),
+	"SETUID":            sig(Int, Int),
+	"SET_ROBUST_LIST":   sig(Int, Hex, Size),
+	"SET_TID_ADDRESS":   sig(Int, Hex),
+	"SHUTDOWN":          sig(Int, Fd, Int),
+	"SIGALTSTACK":       sig(Int, Hex, Hex),
+	"SOCKET":            sig(Fd, Family, Int, Int),
+	"SOCKETPAIR":        sig(Int, Family, Int, Int, Hex),
+	"STAT":              sig(Int, Path, Stat),
+	"STATFS":            sig(Int, Path, Hex),
+	"STATX":             sig(Int, Dirfd, Path, AtFlags, Hex, Hex),
+	"SYMLINK":           sig(Int, Path, Path),
+	"SYMLINKAT":         sig(Int, Path, Dirfd, Path),
+	"SYNC":              sig(Int),
+	"SYSINFO":           sig(Int, Hex),
+	"TGKILL":            sig(Int, Int, Int, Signal),
+	"TIME":              sig(Long, Hex),
+	"TIMES":             sig(Long, Hex),
+	"TKILL":             sig(Int, Int, Signal),
+	"TRUNCATE":          sig(Int, Path, Long),
+	"UMASK":             sig(Mode, Mode),
+	"UNAME":             sig(Int, Hex),
+	"UNLINK":            sig(Int, Path),
+	"UNLINKAT":          sig(Int, Dirfd, Path, AtFlags),
+	"UTIMENSAT":         sig(Int, Dirfd, Path, Hex, AtFlags),
+	"VFORK":             sig(Int),
+	"WAIT4":             sig(Int, Int, Hex, Flags, Hex),
+	"WAITID":            sig(Int, Int, Int, Hex, Flags, Hex),
+	"WRITE":             sig(Long, Fd, BufIn, Size),
+	"WRITEV":            sig(Long, Fd, Iovec, Int),
+}
@@ -2,6 +2,7 @@ package syscalls
 
 import (
 	"fmt"
+	"strings"
 	"syscall"
 )
 
@@ -15,6 +16,17 @@ func GetName(syscallID int) string {
 	}
 }
 
+// GetNum returns the number of a syscall by name, in any case
+func GetNum(name string) (int, bool) {
+	name = strings.ToUpper(name)
+	for num, n := range syscallNames {
+		if n == name {
+			return num, true
+		}
+	}
+	return -1, false
+}
+
 var MapRegs func(regs syscall.PtraceRegs) Regs
 
 type Regs struct {
@@ -1,6 +1,7 @@
 package syscalls
 
 import (
+	"slices"
 	"syscall"
 	"testing"
 )
@@ -18,3 +19,84 @@ func TestGetNameFromImport(t *testing.T) {
 		t.Errorf("expected %s for %d, but got %s", expected, syscallID, name)
 	}
 }
+
+func TestGetSignature(t *testing.T) {
+	sig := GetSignature(syscall.SYS_READ)
+	if n, expected := len(sig.Args), 3; n != expected {
+		t.Errorf("expected %d arguments of READ, but got %d", expected, n)
+	}
+	if kind := sig.Args[1]; kind != BufOut {
+		t.Errorf("expected READ buffer to be BufOut, but got %d", kind)
+	}
+	if n := len(GetSignature(-1).Args); n != 6 {
+		t.Errorf("expected 6 arguments of unknown syscall, but got %d", n)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	for retVal, expected := range map[int]string{
+		-int(syscall.ENOENT):      "-1 ENOENT (No such file or directory)",
+		-int(syscall.EWOULDBLOCK): "-1 EAGAIN (Resource temporarily unavailable)",
+		-int(syscall.EOPNOTSUPP):  "-1 EOPNOTSUPP (Operation not supported)",
+		-512:                      "? ERESTARTSYS (To be restarted if SA_RESTART is set)",
+	} {
+		if str := FormatError(retVal); str != expected {
+			t.Errorf("expected %s for %d, but got %s", expected, retVal, str)
+		}
+	}
+}
+
+func TestGetErrno(t *testing.T) {
+	for name, expected := range map[string]syscall.Errno{
+		"ENOENT":      syscall.ENOENT,
+		"EWOULDBLOCK": syscall.EAGAIN,
+		"ENOTSUP":     syscall.EOPNOTSUPP,
+		"EOPNOTSUPP":  syscall.EOPNOTSUPP,
+		"ERESTARTSYS": 512,
+	} {
+		if errno, ok := GetErrno(name); !ok || errno != int(expected) {
+			t.Errorf("expected %d for %s, but got %d", expected, name, errno)
+		}
+	}
+}
+
+func TestFormatFlags(t *testing.T) {
+	for _, test := range []struct {
+		kind     ArgKind
+		value    int
+		expected string
+	}{
+		{OpenFlags, syscall.O_RDONLY | syscall.O_CLOEXEC, "O_RDONLY|O_CLOEXEC"},
+		{OpenFlags, syscall.O_WRONLY | syscall.O_CREAT | syscall.O_TRUNC, "O_WRONLY|O_CREAT|O_TRUNC"},
+		{OpenFlags, syscall.O_RDWR | 1<<30, "O_RDWR|0x40000000"},
+		{FdFlags, 0, "0"},
+		{FdFlags, syscall.O_CLOEXEC, "O_CLOEXEC"},
+		{FdFlags, syscall.O_NONBLOCK | syscall.O_CLOEXEC, "O_NONBLOCK|O_CLOEXEC"},
+		{ProtFlags, 0, "PROT_NONE"},
+		{MapFlags, syscall.MAP_PRIVATE | syscall.MAP_ANONYMOUS, "MAP_PRIVATE|MAP_ANONYMOUS"},
+		{Whence, 2, "SEEK_END"},
+		{Whence, 7, "0x7"},
+		{AtFlags, 0, "0"},
+		{Flags, 0, "0"},
+		{AccessMode, 0, "F_OK"},
+		{AccessMode, 6, "R_OK|W_OK"},
+		{Resource, syscall.RLIMIT_STACK, "RLIMIT_STACK"},
+		{ClockId, 1, "CLOCK_MONOTONIC"},
+		{TimerFlags, 0, "0"},
+		{CloneFlags, syscall.CLONE_VM | syscall.CLONE_FILES, "CLONE_VM|CLONE_FILES"},
+	} {
+		if str := FormatFlags(test.kind, test.value); str != test.expected {
+			t.Errorf("expected %s for %#x, but got %s", test.expected, test.value, str)
+		}
+	}
+}
+
+func TestGetClass(t *testing.T) {
+	nums, ok := GetClass("file")
+	if !ok || !slices.Contains(nums, syscall.SYS_OPENAT) || slices.Contains(nums, syscall.SYS_READ) {
+		t.Errorf("expected openat and not read in %%file, but got %v", nums)
+	}
+	if _, ok := GetClass("nothing"); ok {
+		t.Errorf("expected no class %%nothing")
+	}
+}
@@ -0,0 +1,30 @@
+//go:build amd64
+
+package tracer
+
+import "syscall"
+
+// not in the syscall package
+const (
+	sysProcessVMReadv  = 310
+	sysProcessVMWritev = 311
+)
+
+func setSyscallNum(tid, num int) error {
+	return modifyRegs(tid, func(regs *syscall.PtraceRegs) {
+		regs.Orig_rax = uint64(num)
+	})
+}
+
+// argReg returns the register of argument n, from 1
+func argReg(regs *syscall.PtraceRegs, n int) *uint64 {
+	return []*uint64{&regs.Rdi, &regs.Rsi, &regs.Rdx, &regs.R10, &regs.R8, &regs.R9}[n-1]
+}
+
+func retReg(regs *syscall.PtraceRegs) *uint64 {
+	return &regs.Rax
+}
+
+func stackPointer(regs *syscall.PtraceRegs) uint64 {
+	return regs.Rsp
+}
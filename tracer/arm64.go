@@ -0,0 +1,37 @@
+//go:build arm64
+
+package tracer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// not in the syscall package
+const (
+	sysProcessVMReadv  = 270
+	sysProcessVMWritev = 271
+	ntArmSystemCall    = 0x404
+)
+
+// setSyscallNum sets the syscall number, which is not in x8 once the
+// syscall is entered, but in a register set of its own
+func setSyscallNum(tid, num int) error {
+	nr := int32(num)
+	iov := syscall.Iovec{Base: (*byte)(unsafe.Pointer(&nr))}
+	iov.SetLen(int(unsafe.Sizeof(nr)))
+	return ptrace(syscall.PTRACE_SETREGSET, tid, ntArmSystemCall, uintptr(unsafe.Pointer(&iov)))
+}
+
+// argReg returns the register of argument n, from 1
+func argReg(regs *syscall.PtraceRegs, n int) *uint64 {
+	return &regs.Regs[n-1]
+}
+
+func retReg(regs *syscall.PtraceRegs) *uint64 {
+	return &regs.Regs[0]
+}
+
+func stackPointer(regs *syscall.PtraceRegs) uint64 {
+	return regs.Sp
+}
@@ -0,0 +1,149 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// https://man7.org/linux/man-pages/man2/ptrace.2.html
+const (
+	ptraceSeize     = 0x4206
+	ptraceInterrupt = 0x4207
+	ptraceListen    = 0x4208
+	ptraceEventStop = 128
+)
+
+// attach seizes a running process, and with threads all of its threads,
+// and interrupts it so its first stop shows up in the main loop
+func (t *Tracer) attach(pid int, threads bool, options int) error {
+	fds, err := readFds(pid)
+	if err != nil {
+		return err
+	}
+	fs, err := readFs(pid)
+	if err != nil {
+		return err
+	}
+	if !threads {
+		return t.seize(pid, options, fds, fs)
+	}
+	// threads may be created while we attach, so list until nothing is new
+	for {
+		entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+		if err != nil {
+			return fmt.Errorf("listing threads of %d: %w", pid, err)
+		}
+		attached := 0
+		for _, entry := range entries {
+			tid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+			if _, ok := t.tasks[tid]; ok {
+				continue
+			}
+			if err := t.seize(tid, options, fds, fs); err != nil {
+				return err
+			}
+			attached++
+		}
+		if attached == 0 {
+			return nil
+		}
+	}
+}
+
+func (t *Tracer) seize(tid, options int, fds fdTable, fs *fsInfo) error {
+	if err := ptrace(ptraceSeize, tid, 0, uintptr(options)); err != nil {
+		return fmt.Errorf("seize %d: %w", tid, err)
+	}
+	t.tasks[tid] = &task{attached: true, fds: fds, fs: fs}
+	if err := ptrace(ptraceInterrupt, tid, 0, 0); err != nil {
+		return fmt.Errorf("interrupt %d: %w", tid, err)
+	}
+	return nil
+}
+
+// stop ends tracing. The thread stopped (if not 0), and threads held by a
+// delay or for their parent's event, are known to be in ptrace-stop
+// already. With kill, processes we started are killed; all others are
+// detached, handing back any signal they were about to receive.
+func (t *Tracer) stop(stopped int, kill bool) {
+	for tid, task := range t.tasks {
+		switch {
+		case kill && !task.attached:
+			_ = syscall.Kill(tid, syscall.SIGKILL)
+		case tid == stopped || !task.wake.IsZero() || task.orphan:
+			_ = ptrace(syscall.PTRACE_DETACH, tid, 0, 0)
+			delete(t.tasks, tid)
+		case task.attached:
+			_ = ptrace(ptraceInterrupt, tid, 0, 0)
+		default:
+			// PTRACE_INTERRUPT only works for seized tracees
+			if task.tgid == 0 {
+				task.tgid = readTgid(tid)
+			}
+			_ = syscall.Tgkill(task.tgid, tid, syscall.SIGSTOP)
+			task.stopping = true
+		}
+	}
+	for len(t.tasks) > 0 {
+		w := <-t.wait()
+		t.waiting = false
+		if w.err != nil {
+			return
+		}
+		task, ok := t.tasks[w.tid]
+		if !ok {
+			continue
+		}
+		if w.status.Exited() || w.status.Signaled() {
+			delete(t.tasks, w.tid)
+			continue
+		}
+		if kill && !task.attached {
+			continue
+		}
+		stopSig := w.status.StopSignal()
+		if task.stopping && stopSig != syscall.SIGSTOP {
+			// wait for the SIGSTOP we sent, so it is not delivered after detaching
+			_ = ptrace(syscall.PTRACE_CONT, w.tid, 0, uintptr(signalToDeliver(w.status)))
+			continue
+		}
+		sig := 0
+		if !task.stopping {
+			sig = signalToDeliver(w.status)
+		}
+		_ = ptrace(syscall.PTRACE_DETACH, w.tid, 0, uintptr(sig))
+		delete(t.tasks, w.tid)
+	}
+}
+
+// signalToDeliver returns the signal of a signal-delivery-stop, otherwise 0
+func signalToDeliver(wstatus syscall.WaitStatus) int {
+	if stopSig := wstatus.StopSignal(); stopSig != syscall.SIGTRAP && stopSig != syscall.SIGTRAP|0x80 && event(wstatus) == 0 {
+		return int(stopSig)
+	}
+	return 0
+}
+
+// listen keeps a seized tracee in group-stop while still reporting events
+func listen(tid int) {
+	_ = ptrace(ptraceListen, tid, 0, 0)
+}
+
+// event returns the PTRACE_EVENT_* of a ptrace-event-stop
+func event(wstatus syscall.WaitStatus) int {
+	return int(wstatus >> 16)
+}
+
+func ptrace(request, pid int, addr, data uintptr) error {
+	_, _, e := syscall.Syscall6(syscall.SYS_PTRACE,
+		uintptr(request), uintptr(pid), addr, data, 0, 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
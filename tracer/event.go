@@ -0,0 +1,49 @@
+package tracer
+
+import (
+	"strace/syscalls"
+	"syscall"
+)
+
+// Event is one of SyscallEnter, SyscallExit, Signal, Exec or Exit
+type Event interface {
+	event()
+}
+
+// SyscallEnter is reported when a thread enters a syscall
+type SyscallEnter struct {
+	Tid int
+	syscalls.Regs
+}
+
+// SyscallExit is reported when a thread returns from a syscall. Regs holds
+// the arguments from entry and the return value.
+type SyscallExit struct {
+	Tid int
+	syscalls.Regs
+}
+
+// Signal is reported when a signal is about to be delivered to a thread
+type Signal struct {
+	Tid    int
+	Signal syscall.Signal
+}
+
+// Exec is reported when a process has replaced its program with execve.
+// FormerTid differs from Tid when the execve was made by a non-leader thread.
+type Exec struct {
+	Tid       int
+	FormerTid int
+}
+
+// Exit is reported when a thread has exited or was killed
+type Exit struct {
+	Tid    int
+	Status syscall.WaitStatus
+}
+
+func (SyscallEnter) event() {}
+func (SyscallExit) event()  {}
+func (Signal) event()       {}
+func (Exec) event()         {}
+func (Exit) event()         {}
@@ -0,0 +1,237 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"os"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// fdTable is the file descriptor table of a process, shared by its threads
+// and by children cloned with CLONE_FILES
+type fdTable map[int]fileDesc
+
+type fileDesc struct {
+	path    string // absolute as in /proc/<pid>/fd, or like "pipe:[1234]"
+	raw     string // as opened, maybe relative
+	cloexec bool
+}
+
+const (
+	closeRangeUnshare = 1 << 1
+	closeRangeCloexec = 1 << 2
+	mfdCloexec        = 1
+)
+
+// readFds reads the table of a process that was not traced from the start
+func readFds(pid int) (fdTable, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return nil, fmt.Errorf("listing fds of %d: %w", pid, err)
+	}
+	fds := fdTable{}
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		path, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", pid, fd))
+		if err != nil {
+			continue // closed meanwhile
+		}
+		fds[fd] = fileDesc{path, path, fdinfoCloexec(pid, fd)}
+	}
+	return fds, nil
+}
+
+// fdinfoCloexec reads the close-on-exec flag from /proc/<pid>/fdinfo/<fd>
+func fdinfoCloexec(pid, fd int) bool {
+	value, err := fdinfo(pid, fd, "flags")
+	if err != nil {
+		return false
+	}
+	flags, _ := strconv.ParseInt(value, 8, 64)
+	return flags&syscall.O_CLOEXEC != 0
+}
+
+// fdinfo reads a field of /proc/<pid>/fdinfo/<fd>, like pos or flags
+func fdinfo(pid, fd int, name string) (string, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/fdinfo/%d", pid, fd))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), name+":"); found {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no %s in fdinfo of %d", name, fd)
+}
+
+// exec returns the table after a successful execve, which is not shared
+func (fds fdTable) exec() fdTable {
+	kept := fdTable{}
+	for fd, desc := range fds {
+		if !desc.cloexec {
+			kept[fd] = desc
+		}
+	}
+	return kept
+}
+
+// forked returns the table of a new child or thread
+func (fds fdTable) forked(cloneFlags int) fdTable {
+	if cloneFlags&syscall.CLONE_FILES != 0 {
+		return fds
+	}
+	return maps.Clone(fds)
+}
+
+// cloneFlags returns the flags of the clone, clone3, fork or vfork being made
+func cloneFlags(tid int, r syscalls.Regs) (int, error) {
+	switch syscalls.GetName(r.SyscallNum) {
+	case "CLONE":
+		return r.Arg1, nil
+	case "CLONE3":
+		// struct clone_args starts with __aligned_u64 flags
+		buf := make([]byte, 8)
+		if err := readMemory(tid, uintptr(r.Arg1), buf); err != nil {
+			return 0, fmt.Errorf("reading clone_args: %w", err)
+		}
+		return int(binary.NativeEndian.Uint64(buf)), nil
+	}
+	return 0, nil
+}
+
+// trackFds updates the table of a thread after a successful syscall
+func trackFds(tid int, state *task, r syscalls.Regs) error {
+	if syscalls.IsError(r.RetVal) {
+		return nil
+	}
+	fds := state.fds
+	fd := r.RetVal
+	switch syscalls.GetName(r.SyscallNum) {
+	case "OPEN":
+		return fds.open(tid, fd, state.dir(syscalls.AT_FDCWD), r.Arg1, r.Arg2&syscall.O_CLOEXEC != 0)
+	case "CREAT":
+		return fds.open(tid, fd, state.dir(syscalls.AT_FDCWD), r.Arg1, false)
+	case "OPENAT":
+		return fds.open(tid, fd, state.dir(r.Arg1), r.Arg2, r.Arg3&syscall.O_CLOEXEC != 0)
+	case "OPENAT2":
+		// struct open_how starts with __u64 flags
+		buf := make([]byte, 8)
+		if err := readMemory(tid, uintptr(r.Arg3), buf); err != nil {
+			return fmt.Errorf("reading open_how: %w", err)
+		}
+		return fds.open(tid, fd, state.dir(r.Arg1), r.Arg2, binary.NativeEndian.Uint64(buf)&syscall.O_CLOEXEC != 0)
+	case "CLOSE":
+		delete(fds, int(int32(r.Arg1)))
+	case "CLOSE_RANGE":
+		if r.Arg3&closeRangeUnshare != 0 {
+			state.fds = maps.Clone(fds)
+			fds = state.fds
+		}
+		for fd, desc := range fds {
+			if uint32(r.Arg1) <= uint32(fd) && uint32(fd) <= uint32(r.Arg2) {
+				if r.Arg3&closeRangeCloexec != 0 {
+					desc.cloexec = true
+					fds[fd] = desc
+				} else {
+					delete(fds, fd)
+				}
+			}
+		}
+	case "DUP", "DUP2":
+		fds.dup(int(int32(r.Arg1)), fd, false)
+	case "DUP3":
+		fds.dup(int(int32(r.Arg1)), fd, r.Arg3&syscall.O_CLOEXEC != 0)
+	case "FCNTL":
+		switch int(int32(r.Arg2)) {
+		case syscall.F_DUPFD:
+			fds.dup(int(int32(r.Arg1)), fd, false)
+		case syscall.F_DUPFD_CLOEXEC:
+			fds.dup(int(int32(r.Arg1)), fd, true)
+		case syscall.F_SETFD:
+			if desc, ok := fds[int(int32(r.Arg1))]; ok {
+				desc.cloexec = r.Arg3&syscall.FD_CLOEXEC != 0
+				fds[int(int32(r.Arg1))] = desc
+			}
+		}
+	case "SOCKET":
+		fds.anonymous(tid, fd, r.Arg2&syscall.SOCK_CLOEXEC != 0)
+	case "ACCEPT":
+		fds.anonymous(tid, fd, false)
+	case "ACCEPT4":
+		fds.anonymous(tid, fd, r.Arg4&syscall.SOCK_CLOEXEC != 0)
+	case "EVENTFD", "EPOLL_CREATE", "INOTIFY_INIT", "SIGNALFD":
+		fds.anonymous(tid, fd, false)
+	case "EPOLL_CREATE1", "INOTIFY_INIT1":
+		// flags only, with *_CLOEXEC the same as O_CLOEXEC
+		fds.anonymous(tid, fd, r.Arg1&syscall.O_CLOEXEC != 0)
+	case "EVENTFD2", "TIMERFD_CREATE":
+		// initval or clockid, then flags
+		fds.anonymous(tid, fd, r.Arg2&syscall.O_CLOEXEC != 0)
+	case "SIGNALFD4":
+		fds.anonymous(tid, fd, r.Arg4&syscall.O_CLOEXEC != 0)
+	case "MEMFD_CREATE":
+		fds.anonymous(tid, fd, r.Arg2&mfdCloexec != 0)
+	case "PIPE":
+		return fds.pair(tid, r.Arg1, false)
+	case "PIPE2":
+		return fds.pair(tid, r.Arg1, r.Arg2&syscall.O_CLOEXEC != 0)
+	case "SOCKETPAIR":
+		return fds.pair(tid, r.Arg4, r.Arg2&syscall.SOCK_CLOEXEC != 0)
+	}
+	return nil
+}
+
+// open adds an fd opened with a path relative to dir
+func (fds fdTable) open(tid, fd int, dir string, pathAddr int, cloexec bool) error {
+	raw, err := readString(tid, uintptr(pathAddr))
+	if err != nil {
+		return fmt.Errorf("reading path: %w", err)
+	}
+	path, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", tid, fd))
+	if err != nil {
+		path = resolve(dir, raw) // closed by another thread meanwhile
+	}
+	fds[fd] = fileDesc{path, raw, cloexec}
+	return nil
+}
+
+// dup copies an fd, which does not copy its close-on-exec flag
+func (fds fdTable) dup(oldFd, newFd int, cloexec bool) {
+	if oldFd == newFd {
+		return // dup2 does nothing
+	}
+	desc := fds[oldFd]
+	desc.cloexec = cloexec
+	fds[newFd] = desc
+}
+
+// anonymous adds an fd without a path, named like in /proc/<pid>/fd
+func (fds fdTable) anonymous(tid, fd int, cloexec bool) {
+	path, _ := os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", tid, fd))
+	fds[fd] = fileDesc{path, path, cloexec}
+}
+
+// pair adds the two fds of pipe or socketpair, written to int fds[2]
+func (fds fdTable) pair(tid, addr int, cloexec bool) error {
+	buf := make([]byte, 8)
+	if err := readMemory(tid, uintptr(addr), buf); err != nil {
+		return fmt.Errorf("reading fds: %w", err)
+	}
+	fds.anonymous(tid, int(int32(binary.NativeEndian.Uint32(buf))), cloexec)
+	fds.anonymous(tid, int(int32(binary.NativeEndian.Uint32(buf[4:]))), cloexec)
+	return nil
+}
@@ -0,0 +1,68 @@
+package tracer
+
+import (
+	"os"
+	"strace/syscalls"
+	"syscall"
+	"testing"
+)
+
+func TestFdTable(t *testing.T) {
+	fds := fdTable{3: {"/a", "a", true}}
+	fds.dup(3, 4, false)
+	fds.dup(3, 5, true)
+
+	child := fds.forked(0)
+	delete(child, 4)
+	if _, ok := fds[4]; !ok {
+		t.Errorf("expected fd 4 to be left open by the child")
+	}
+	thread := fds.forked(syscall.CLONE_FILES)
+	delete(thread, 4)
+	if _, ok := fds[4]; ok {
+		t.Errorf("expected fd 4 to be closed by the thread")
+	}
+
+	if execed := fds.exec(); len(execed) != 0 {
+		t.Errorf("expected no fds after exec, but got %v", execed)
+	}
+}
+
+func TestTrackFdsCloexec(t *testing.T) {
+	for name, r := range map[string]syscalls.Regs{
+		"EPOLL_CREATE1":  {Arg1: syscall.O_CLOEXEC},
+		"INOTIFY_INIT1":  {Arg1: syscall.O_CLOEXEC},
+		"EVENTFD2":       {Arg2: syscall.O_CLOEXEC},
+		"TIMERFD_CREATE": {Arg1: 1, Arg2: syscall.O_CLOEXEC},
+		"SIGNALFD4":      {Arg4: syscall.O_CLOEXEC},
+	} {
+		num, ok := syscalls.GetNum(name)
+		if !ok {
+			t.Fatalf("no syscall %s", name)
+		}
+		r.SyscallNum, r.RetVal = num, 3
+		state := &task{fds: fdTable{}}
+		if err := trackFds(os.Getpid(), state, r); err != nil {
+			t.Fatal(err)
+		}
+		if !state.fds[3].cloexec {
+			t.Errorf("expected %s to open fd 3 with close-on-exec", name)
+		}
+	}
+}
+
+func TestReadFds(t *testing.T) {
+	file, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	fds, err := readFds(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc := fds[int(file.Fd())]; desc.path != os.Args[0] || !desc.cloexec {
+		t.Errorf("expected %s with close-on-exec, but got %v", os.Args[0], desc)
+	}
+}
@@ -0,0 +1,187 @@
+package tracer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// A tracee's memory is read with process_vm_readv, falling back to
+// /proc/<pid>/mem where it is not available (kernels before 3.2, seccomp)
+// and to word-sized PTRACE_PEEKDATA, which only works from the tracer thread.
+// Writes are alike, where process_vm_writev fails on read-only pages.
+var memoryReaders = []memoryAccess{
+	processVMReadv,
+	procMemRead,
+	peekData,
+}
+
+var memoryWriters = []memoryAccess{
+	processVMWritev,
+	procMemWrite,
+	pokeData,
+}
+
+type memoryAccess func(pid int, addr uintptr, buf []byte) (int, error)
+
+var pageSize = uintptr(os.Getpagesize())
+
+// readMemory fills buf from the tracee's memory at addr
+func readMemory(pid int, addr uintptr, buf []byte) error {
+	return accessMemory(memoryReaders, pid, addr, buf, true)
+}
+
+// writeMemory writes data to the tracee's memory at addr
+func writeMemory(pid int, addr uintptr, data []byte) error {
+	return accessMemory(memoryWriters, pid, addr, data, false)
+}
+
+// accessMemory tries one way after the other, until one works or, when
+// faultIsFinal, the address is found to be invalid. A write fault is not,
+// as process_vm_writev faults on read-only pages that the others can write.
+func accessMemory(ways []memoryAccess, pid int, addr uintptr, buf []byte, faultIsFinal bool) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, access := range ways {
+		n, err := access(pid, addr, buf)
+		if err == nil && n < len(buf) {
+			err = syscall.EFAULT // partly unmapped
+		}
+		if err == nil || faultIsFinal && errors.Is(err, syscall.EFAULT) || errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// readString reads a NUL-terminated string, page by page so that no read
+// crosses into an unmapped page after the string
+func readString(pid int, addr uintptr) (string, error) {
+	var str []byte
+	for {
+		buf := make([]byte, pageSize-addr%pageSize)
+		if err := readMemory(pid, addr, buf); err != nil {
+			return string(str), err
+		}
+		if i := bytes.IndexByte(buf, 0); i >= 0 {
+			return string(append(str, buf[:i]...)), nil
+		}
+		str = append(str, buf...)
+		addr += uintptr(len(buf))
+	}
+}
+
+// mappingStart returns where the mapping of the tracee's memory containing
+// addr starts, from /proc/<pid>/maps
+func mappingStart(pid int, addr uintptr) (uintptr, error) {
+	file, err := os.Open("/proc/" + strconv.Itoa(pid) + "/maps")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// start-end perms offset dev inode path, in hex
+		var start, end uintptr
+		if _, err := fmt.Sscanf(scanner.Text(), "%x-%x", &start, &end); err != nil {
+			return 0, fmt.Errorf("reading /proc/%d/maps: %w", pid, err)
+		}
+		if start <= addr && addr < end {
+			return start, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%#x not mapped", addr)
+}
+
+func processVMReadv(pid int, addr uintptr, buf []byte) (int, error) {
+	n, err := processVM(sysProcessVMReadv, pid, addr, buf)
+	if err != nil {
+		return 0, fmt.Errorf("process_vm_readv %#x: %w", addr, err)
+	}
+	return n, nil
+}
+
+func processVMWritev(pid int, addr uintptr, data []byte) (int, error) {
+	n, err := processVM(sysProcessVMWritev, pid, addr, data)
+	if err != nil {
+		return 0, fmt.Errorf("process_vm_writev %#x: %w", addr, err)
+	}
+	return n, nil
+}
+
+func processVM(trap uintptr, pid int, addr uintptr, buf []byte) (int, error) {
+	local := syscall.Iovec{Base: &buf[0]}
+	local.SetLen(len(buf))
+	remote := [2]uintptr{addr, uintptr(len(buf))} // struct iovec of the tracee
+	n, _, errno := syscall.Syscall6(trap, uintptr(pid),
+		uintptr(unsafe.Pointer(&local)), 1, uintptr(unsafe.Pointer(&remote)), 1, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func procMemRead(pid int, addr uintptr, buf []byte) (int, error) {
+	file, err := os.Open("/proc/" + strconv.Itoa(pid) + "/mem")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	n, err := file.ReadAt(buf, int64(addr))
+	if errors.Is(err, syscall.EIO) {
+		err = syscall.EFAULT
+	}
+	if err != nil {
+		return n, fmt.Errorf("read /proc/%d/mem %#x: %w", pid, addr, err)
+	}
+	return n, nil
+}
+
+func procMemWrite(pid int, addr uintptr, data []byte) (int, error) {
+	file, err := os.OpenFile("/proc/"+strconv.Itoa(pid)+"/mem", os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	n, err := file.WriteAt(data, int64(addr))
+	if errors.Is(err, syscall.EIO) {
+		err = syscall.EFAULT
+	}
+	if err != nil {
+		return n, fmt.Errorf("write /proc/%d/mem %#x: %w", pid, addr, err)
+	}
+	return n, nil
+}
+
+func peekData(pid int, addr uintptr, buf []byte) (int, error) {
+	n, err := syscall.PtracePeekData(pid, addr, buf)
+	if errors.Is(err, syscall.EIO) {
+		err = syscall.EFAULT
+	}
+	if err != nil {
+		return n, fmt.Errorf("ptrace peek %#x: %w", addr, err)
+	}
+	return n, nil
+}
+
+func pokeData(pid int, addr uintptr, data []byte) (int, error) {
+	n, err := syscall.PtracePokeData(pid, addr, data)
+	if errors.Is(err, syscall.EIO) {
+		err = syscall.EFAULT
+	}
+	if err != nil {
+		return n, fmt.Errorf("ptrace poke %#x: %w", addr, err)
+	}
+	return n, nil
+}
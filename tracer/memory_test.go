@@ -0,0 +1,156 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strace/syscalls"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// startStopped starts a process that is stopped after exec, and returns
+// the address of its argument arg
+func startStopped(tb testing.TB, arg string) (int, uintptr) {
+	cmd := exec.Command("true", arg)
+	if err := New().Start(cmd); err != nil {
+		tb.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	tb.Cleanup(func() {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+		_, _ = syscall.Wait4(pid, nil, 0, nil)
+	})
+
+	info, err := syscalls.GetInfo(pid)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// argc, argv[0], argv[1]
+	buf := make([]byte, 8)
+	if err := readMemory(pid, uintptr(info.StackPointer)+16, buf); err != nil {
+		tb.Fatal(err)
+	}
+	return pid, uintptr(binary.NativeEndian.Uint64(buf))
+}
+
+func TestReadMemory(t *testing.T) {
+	arg := strings.Repeat("0123456789", 1000)
+	pid, addr := startStopped(t, arg)
+
+	for i, read := range memoryReaders {
+		buf := make([]byte, len(arg)+1)
+		if n, err := read(pid, addr, buf); err != nil || n != len(buf) {
+			t.Fatalf("reader %d: read %d bytes: %v", i, n, err)
+		}
+		if str := string(buf); str != arg+"\x00" {
+			t.Errorf("reader %d: expected the argument, but got %q", i, str)
+		}
+	}
+	if str, err := readString(pid, addr); err != nil || str != arg {
+		t.Errorf("expected the argument, but got %q: %v", str, err)
+	}
+	if err := readMemory(pid, 0, make([]byte, 1)); err == nil {
+		t.Errorf("expected error reading address 0")
+	}
+}
+
+func TestWriteReadOnlyMemory(t *testing.T) {
+	pid, _ := startStopped(t, "")
+	info, err := syscalls.GetInfo(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the code, which process_vm_writev cannot write
+	addr := uintptr(info.InstructionPointer)
+	code := make([]byte, 16)
+	if err := readMemory(pid, addr, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processVMWritev(pid, addr, code); err == nil {
+		t.Skip("code is writable")
+	}
+	code[0] ^= 0xff
+	if err := writeMemory(pid, addr, code); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(code))
+	if err := readMemory(pid, addr, buf); err != nil || string(buf) != string(code) {
+		t.Errorf("expected %x, but got %x: %v", code, buf, err)
+	}
+}
+
+func TestMappingStart(t *testing.T) {
+	pid, _ := startStopped(t, "")
+	info, err := syscalls.GetInfo(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := uintptr(info.StackPointer)
+	if start, err := mappingStart(pid, sp); err != nil || start > sp || start%pageSize != 0 {
+		t.Errorf("expected the start of the stack below %#x, but got %#x: %v", sp, start, err)
+	}
+	if _, err := mappingStart(pid, 0); err == nil {
+		t.Errorf("expected error for address 0")
+	}
+}
+
+// readPerByte is how strings used to be read
+func readPerByte(pid int, addr uintptr) (string, error) {
+	s := ""
+	buf := []byte{1}
+	for i := addr; ; i++ {
+		if c, err := syscall.PtracePeekText(pid, i, buf); err != nil {
+			return s, fmt.Errorf("ptrace peek %#x: %w", i, err)
+		} else if c == 0 || buf[0] == 0 {
+			break
+		}
+		s += string(buf)
+	}
+	return s, nil
+}
+
+func benchmarkReadString(b *testing.B, read func(pid int, addr uintptr) (string, error)) {
+	arg := strings.Repeat("x", 4096)
+	pid, addr := startStopped(b, arg)
+	b.SetBytes(int64(len(arg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if str, err := read(pid, addr); err != nil || len(str) != len(arg) {
+			b.Fatalf("read %d bytes: %v", len(str), err)
+		}
+	}
+}
+
+func BenchmarkReadStringPerByte(b *testing.B) {
+	benchmarkReadString(b, readPerByte)
+}
+
+func BenchmarkReadString(b *testing.B) {
+	benchmarkReadString(b, readString)
+}
+
+func benchmarkReader(b *testing.B, read func(pid int, addr uintptr, buf []byte) (int, error)) {
+	pid, addr := startStopped(b, strings.Repeat("x", 4096))
+	buf := make([]byte, 4096)
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if n, err := read(pid, addr, buf); err != nil || n != len(buf) {
+			b.Fatalf("read %d bytes: %v", n, err)
+		}
+	}
+}
+
+func BenchmarkProcessVMReadv(b *testing.B) {
+	benchmarkReader(b, processVMReadv)
+}
+
+func BenchmarkProcMemRead(b *testing.B) {
+	benchmarkReader(b, procMemRead)
+}
+
+func BenchmarkPeekData(b *testing.B) {
+	benchmarkReader(b, peekData)
+}
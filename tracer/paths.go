@@ -0,0 +1,61 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strace/syscalls"
+	"syscall"
+)
+
+// fsInfo is the working directory of a process, shared by its threads and
+// by children cloned with CLONE_FS
+type fsInfo struct {
+	cwd string
+}
+
+// readFs reads the working directory of a process
+func readFs(pid int) (*fsInfo, error) {
+	cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return nil, fmt.Errorf("reading cwd of %d: %w", pid, err)
+	}
+	return &fsInfo{cwd}, nil
+}
+
+// forked returns the working directory of a new child or thread
+func (fs *fsInfo) forked(cloneFlags int) *fsInfo {
+	if fs == nil || cloneFlags&syscall.CLONE_FS != 0 {
+		return fs
+	}
+	return &fsInfo{fs.cwd}
+}
+
+// trackCwd updates the working directory after a successful chdir or fchdir
+func trackCwd(tid int, state *task, r syscalls.Regs) error {
+	if syscalls.IsError(r.RetVal) {
+		return nil
+	}
+	switch syscalls.GetName(r.SyscallNum) {
+	case "CHDIR", "FCHDIR":
+		fs, err := readFs(tid)
+		if err != nil {
+			return err
+		}
+		if state.fs == nil {
+			state.fs = fs
+		} else {
+			state.fs.cwd = fs.cwd // for all sharing it
+		}
+	}
+	return nil
+}
+
+// resolve returns path relative to dir as an absolute path, lexically,
+// or cleaned when dir is unknown
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,33 @@
+package tracer
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestFsInfo(t *testing.T) {
+	fs := &fsInfo{"/a"}
+	child := fs.forked(0)
+	child.cwd = "/b"
+	if fs.cwd != "/a" {
+		t.Errorf("expected the cwd to be left by the child, but got %s", fs.cwd)
+	}
+	thread := fs.forked(syscall.CLONE_FS)
+	thread.cwd = "/c"
+	if fs.cwd != "/c" {
+		t.Errorf("expected the cwd to be changed by the thread, but got %s", fs.cwd)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	for _, test := range []struct{ dir, path, expected string }{
+		{"/a", "b", "/a/b"},
+		{"/a", "../b/./c", "/b/c"},
+		{"/a", "/b/", "/b"},
+		{"", "b", "b"},
+	} {
+		if path := resolve(test.dir, test.path); path != test.expected {
+			t.Errorf("resolve(%q, %q): expected %s, but got %s", test.dir, test.path, test.expected, path)
+		}
+	}
+}
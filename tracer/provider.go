@@ -0,0 +1,216 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strace/syscalls"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type provider struct {
+	tracer  *Tracer
+	pid     int
+	task    *task       // of pid
+	results map[int]int // return values of skipped syscalls, by tid
+	time    time.Time   // of the current stop
+	retVal  int         // of the current syscall, at exit
+	scratch int         // bytes below the stack used by SetArgString at this stop
+}
+
+func (p *provider) Tid() int {
+	return p.pid
+}
+
+func (p *provider) Time() time.Time {
+	return p.time
+}
+
+func (p *provider) EntryTime() time.Time {
+	return p.task.entered
+}
+
+func (p *provider) Tasks() int {
+	return len(p.tracer.tasks)
+}
+
+func (p *provider) Pid() int {
+	if p.task.tgid == 0 {
+		p.task.tgid = readTgid(p.pid)
+	}
+	return p.task.tgid
+}
+
+// readTgid reads the process ID of a thread, which is the thread ID
+// if it cannot be read
+func readTgid(tid int) int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", tid))
+	if err != nil {
+		return tid
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(line, "Tgid:"); found {
+			if tgid, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return tgid
+			}
+		}
+	}
+	return tid
+}
+
+func (p *provider) ReadPtraceText(addr uintptr) (string, error) {
+	return readString(p.pid, addr)
+}
+
+func (p *provider) ReadPtraceTextBuf(addr uintptr, size int) (string, error) {
+	if size < 0 {
+		return "", fmt.Errorf("read %#x: negative length %d", addr, size)
+	}
+	buf := make([]byte, size)
+	err := readMemory(p.pid, addr, buf)
+	return string(buf), err
+}
+
+func (p *provider) ReadMemory(addr uintptr, buf []byte) error {
+	return readMemory(p.pid, addr, buf)
+}
+
+func (p *provider) WriteMemory(addr uintptr, data []byte) error {
+	return writeMemory(p.pid, addr, data)
+}
+
+func (p *provider) SetSyscallNum(num int) error {
+	if err := setSyscallNum(p.pid, num); err != nil {
+		return fmt.Errorf("set syscall number: %w", err)
+	}
+	return nil
+}
+
+func (p *provider) SetArg(n, value int) error {
+	if n < 1 || n > 6 {
+		return fmt.Errorf("set argument %d: no such argument", n)
+	}
+	err := modifyRegs(p.pid, func(regs *syscall.PtraceRegs) {
+		*argReg(regs, n) = uint64(value)
+	})
+	if err != nil {
+		return err
+	}
+	// as the kernel sees it, for fd tracking and the interceptors after
+	r := &p.task.regs
+	*[]*int{&r.Arg1, &r.Arg2, &r.Arg3, &r.Arg4, &r.Arg5, &r.Arg6}[n-1] = value
+	return nil
+}
+
+// redZone is the part below the stack pointer that functions may use
+// without moving it, on amd64
+const redZone = 128
+
+func (p *provider) SetArgString(n int, value string) error {
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(p.pid, &regs); err != nil {
+		return fmt.Errorf("get regs: %w", err)
+	}
+	data := append([]byte(value), 0)
+	p.scratch += (len(data) + 7) &^ 7
+	// the thread does not run until the kernel has read the string, and a
+	// signal handler that overwrites it is called after the syscall, or
+	// before it is restarted, seen by interceptors again
+	sp := uintptr(stackPointer(&regs))
+	addr := sp - redZone - uintptr(p.scratch)
+	// the stack is not grown by writes from another process
+	if start, err := mappingStart(p.pid, sp); err != nil {
+		return fmt.Errorf("set argument %d: stack: %w", n, err)
+	} else if addr < start || addr > sp {
+		return fmt.Errorf("set argument %d: no room below the stack for %d bytes", n, len(data))
+	}
+	if err := p.WriteMemory(addr, data); err != nil {
+		return fmt.Errorf("set argument %d: %w", n, err)
+	}
+	return p.SetArg(n, int(addr))
+}
+
+func (p *provider) SetRetVal(value int) error {
+	err := modifyRegs(p.pid, func(regs *syscall.PtraceRegs) {
+		*retReg(regs) = uint64(value)
+	})
+	if err != nil {
+		return err
+	}
+	p.retVal = value
+	return nil
+}
+
+func (p *provider) SkipSyscall(retVal int) error {
+	if err := p.SetSyscallNum(-1); err != nil {
+		return err
+	}
+	p.results[p.pid] = retVal
+	return nil
+}
+
+func (p *provider) Delay(d time.Duration) {
+	p.task.delay += d
+}
+
+func (p *provider) FileName(fd int) string {
+	return p.task.fds[fd].path
+}
+
+func (p *provider) RawFileName(fd int) string {
+	return p.task.fds[fd].raw
+}
+
+func (p *provider) FileDescriptor(filename string) int {
+	if fds := p.FileDescriptors(filename); len(fds) > 0 {
+		return fds[0]
+	}
+	return -1
+}
+
+func (p *provider) FileDescriptors(filename string) []int {
+	path := p.ResolvePath(syscalls.AT_FDCWD, filename)
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real // canonical, as the paths of fds are
+	}
+	var fds []int
+	for fd, desc := range p.task.fds {
+		if desc.path == path {
+			fds = append(fds, fd)
+		}
+	}
+	slices.Sort(fds)
+	return fds
+}
+
+func (p *provider) FileOffset(fd int) (int64, error) {
+	value, err := fdinfo(p.pid, fd, "pos")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (p *provider) Cwd() string {
+	return p.task.dir(syscalls.AT_FDCWD)
+}
+
+func (p *provider) ResolvePath(dirfd int, path string) string {
+	return resolve(p.task.dir(dirfd), path)
+}
+
+func modifyRegs(tid int, modify func(regs *syscall.PtraceRegs)) error {
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(tid, &regs); err != nil {
+		return fmt.Errorf("get regs: %w", err)
+	}
+	modify(&regs)
+	if err := syscall.PtraceSetRegs(tid, &regs); err != nil {
+		return fmt.Errorf("set regs: %w", err)
+	}
+	return nil
+}
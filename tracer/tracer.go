@@ -0,0 +1,614 @@
+// Package tracer traces processes with ptrace(2), calling interceptors at
+// every syscall entry and exit and reporting what happens as events.
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strace/interceptor"
+	"strace/syscalls"
+	"syscall"
+	"time"
+)
+
+const ptraceOptions = syscall.PTRACE_O_TRACESYSGOOD |
+	syscall.PTRACE_O_TRACEEXEC // no extra SIGTRAP after execve
+
+const followOptions = syscall.PTRACE_O_TRACEFORK |
+	syscall.PTRACE_O_TRACEVFORK |
+	syscall.PTRACE_O_TRACECLONE
+
+// Tracer traces started or attached processes until they are gone.
+//
+// The kernel only accepts ptrace requests from the thread that attached, so
+// Start, Attach and Run must be called from the same goroutine. The first of
+// them locks it to its OS thread.
+type Tracer struct {
+	Follow bool        // trace child processes, and all threads of attached processes
+	Policy ErrorPolicy // what to do when tracing a syscall fails
+	Log    io.Writer   // where to report exits and skipped errors, if not nil
+
+	tasks        map[int]*task // every traced thread, by tid
+	roots        []int         // started or attached processes
+	provider     *provider
+	interceptors []interceptor.Interceptor
+	waits        chan waitResult
+	next         chan struct{} // asks waitAll for the next state change
+	waiting      bool          // whether waitAll was asked and not yet received from
+	events       chan Event
+	thread       int // OS thread doing ptrace requests
+}
+
+type task struct {
+	started   bool          // initial stop seen
+	attached  bool          // seized, not started by us
+	stopping  bool          // SIGSTOP sent to detach
+	inSyscall bool          // entry seen, waiting for exit
+	regs      syscalls.Regs // syscall number and arguments at entry
+	entered   time.Time     // of the syscall-stop at entry
+	fds       fdTable
+	fs        *fsInfo
+	tgid      int           // process ID, 0 until looked up
+	delay     time.Duration // to hold the thread in its current stop
+	wake      time.Time     // when to resume the thread held, or zero
+	wakeSig   int           // signal to deliver then
+	orphan    bool          // initial stop seen before the parent's clone event, held until then
+}
+
+// dir returns the directory that paths relative to dirfd are resolved
+// against, or "" if unknown
+func (t *task) dir(dirfd int) string {
+	if int(int32(dirfd)) == syscalls.AT_FDCWD {
+		if t.fs == nil {
+			return ""
+		}
+		return t.fs.cwd
+	}
+	return t.fds[int(int32(dirfd))].path
+}
+
+// New returns a Tracer aborting on errors
+func New() *Tracer {
+	t := &Tracer{
+		Policy: PolicyAbort,
+		tasks:  map[int]*task{},
+		provider: &provider{
+			results: make(map[int]int),
+		},
+	}
+	t.provider.tracer = t
+	return t
+}
+
+// Provider gives interceptors access to the tracees
+func (t *Tracer) Provider() interceptor.Provider {
+	return t.provider
+}
+
+// Register adds interceptors, called in order of registration
+func (t *Tracer) Register(interceptors ...interceptor.Interceptor) {
+	t.interceptors = append(t.interceptors, interceptors...)
+}
+
+// Events returns a channel receiving everything that happens to the tracees.
+// It must be called before Run, and the channel must be drained since Run
+// blocks on it. It is closed when Run returns.
+func (t *Tracer) Events() <-chan Event {
+	if t.events == nil {
+		t.events = make(chan Event, 64)
+	}
+	return t.events
+}
+
+// Start starts the command with tracing enabled, stopped at its first
+// instruction until Run
+func (t *Tracer) Start(cmd *exec.Cmd) error {
+	if err := t.lockThread(); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("cmd start: %w", err)
+	}
+	err = cmd.Wait() // cmd is paused
+	if err != nil {
+		var e *exec.ExitError
+		if !errors.As(err, &e) || e.ProcessState.Sys().(syscall.WaitStatus).StopSignal() != syscall.SIGTRAP {
+			// expected "stop signal: trace/breakpoint trap" (5)
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("expected trap: %w", err)
+		}
+	}
+
+	pid := cmd.Process.Pid
+	err = syscall.PtraceSetOptions(pid, t.options())
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("ptrace set options: %w", err)
+	}
+	fds, err := readFds(pid) // inherited from us
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	fs, err := readFs(pid)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	t.tasks[pid] = &task{started: true, fds: fds, fs: fs}
+	t.roots = append(t.roots, pid)
+	return nil
+}
+
+// Attach seizes a running process, and with Follow all of its threads. It is
+// left running when tracing stops.
+func (t *Tracer) Attach(pid int) error {
+	if err := t.lockThread(); err != nil {
+		return err
+	}
+	t.roots = append(t.roots, pid)
+	return t.attach(pid, t.Follow, t.options())
+}
+
+func (t *Tracer) options() int {
+	if t.Follow {
+		return ptraceOptions | followOptions
+	}
+	return ptraceOptions
+}
+
+func (t *Tracer) lockThread() error {
+	if t.thread == 0 {
+		runtime.LockOSThread()
+		t.thread = syscall.Gettid()
+	} else if t.thread != syscall.Gettid() {
+		return errors.New("tracer used from another goroutine")
+	}
+	return nil
+}
+
+// Run traces until all tracees are gone. When ctx is done, all tracees are
+// detached and ctx.Err() is returned. Other children of the process ending
+// meanwhile are reaped, and ignored. When tracing fails and the policy does
+// not allow to continue, tracees are killed or detached deliberately before
+// the error is returned.
+func (t *Tracer) Run(ctx context.Context) error {
+	if t.events != nil {
+		defer close(t.events)
+	}
+	if err := t.lockThread(); err != nil {
+		return err
+	}
+	defer runtime.UnlockOSThread()
+	t.waits = make(chan waitResult)
+	t.next = make(chan struct{})
+	t.waiting = false
+	done := make(chan struct{})
+	defer close(done)
+	go waitAll(t.next, t.waits, done)
+
+	current := 0 // thread in ptrace-stop while being handled
+	defer func() {
+		if r := recover(); r != nil {
+			t.stop(current, t.Policy != PolicyDetach)
+			panic(r)
+		}
+	}()
+
+	for tid, state := range t.tasks {
+		if state.started { // started processes are already stopped
+			if err := resume(tid, 0); err != nil {
+				t.stop(tid, t.Policy != PolicyDetach)
+				return err
+			}
+		}
+	}
+
+	for len(t.tasks) > 0 {
+		if ctx.Err() != nil {
+			t.stop(0, false)
+			return ctx.Err()
+		}
+		var wake <-chan time.Time
+		if at := t.nextWake(); !at.IsZero() {
+			wake = time.After(time.Until(at))
+		}
+		var w waitResult
+		select {
+		case <-ctx.Done():
+			continue
+		case now := <-wake:
+			if err := t.wakeUp(now); err != nil {
+				t.stop(0, t.Policy != PolicyDetach)
+				return err
+			}
+			continue
+		case w = <-t.wait():
+			t.waiting = false
+		}
+		if w.err == syscall.ECHILD {
+			break
+		} else if w.err != nil {
+			t.stop(0, t.Policy != PolicyDetach)
+			return fmt.Errorf("wait4: %w", w.err)
+		}
+
+		current = w.tid
+		t.provider.time = w.time
+		if err := t.handle(ctx, w.tid, w.status); err != nil {
+			t.stop(w.tid, t.Policy != PolicyDetach)
+			return err
+		}
+		current = 0
+	}
+	return nil
+}
+
+// handle processes a state change of a thread and resumes it. Errors are
+// returned only when the policy requires tracing to stop; the thread is then
+// still stopped.
+func (t *Tracer) handle(ctx context.Context, tid int, wstatus syscall.WaitStatus) error {
+	if wstatus.Exited() || wstatus.Signaled() {
+		if _, ok := t.tasks[tid]; !ok {
+			return nil // not a tracee, like the command of -o |cmd
+		}
+		for _, inter := range t.interceptors {
+			if observer, ok := inter.(interceptor.Observer); ok {
+				observer.Exit(tid, wstatus)
+			}
+		}
+		delete(t.tasks, tid)
+		t.emit(ctx, Exit{tid, wstatus})
+		if err := t.releaseOrphans(); err != nil {
+			return err
+		}
+		if slices.Contains(t.roots, tid) {
+			if wstatus.Exited() {
+				t.log("target process exited with code %d\n", wstatus.ExitStatus())
+			} else {
+				t.log("target process killed by signal %v\n", wstatus.Signal())
+			}
+		}
+		return nil
+	}
+
+	state, ok := t.tasks[tid]
+	if !ok {
+		// a new child may report its initial stop before the parent's event,
+		// which tells what it inherits
+		state = &task{orphan: true}
+		t.tasks[tid] = state
+	}
+
+	if event(wstatus) == ptraceEventStop && state.started && wstatus.StopSignal() != syscall.SIGTRAP {
+		// group-stop of a seized tracee: stay stopped until SIGCONT
+		listen(tid)
+		return nil
+	}
+
+	sig, err := t.stopped(ctx, tid, state, wstatus)
+	if err != nil {
+		err = fmt.Errorf("pid %d: %w", tid, err)
+		if t.Policy != PolicyContinue {
+			return err
+		}
+		t.log("error: %v\n", err)
+	}
+	if state.orphan {
+		return nil // resumed at the parent's event
+	}
+	if state.delay > 0 {
+		// held in ptrace-stop until the main loop wakes it up
+		state.wake = t.provider.time.Add(state.delay)
+		state.wakeSig = sig
+		state.delay = 0
+		return nil
+	}
+	return resume(tid, sig)
+}
+
+// releaseOrphans resumes the children held for their parent's event when
+// no other thread is left to report it, as when the parent was killed
+func (t *Tracer) releaseOrphans() error {
+	for _, state := range t.tasks {
+		if !state.orphan {
+			return nil
+		}
+	}
+	for tid, state := range t.tasks {
+		state.orphan = false
+		state.fds = fdTable{}
+		if err := resume(tid, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextWake returns when the next thread held by a delay is resumed, or
+// zero if none is held
+func (t *Tracer) nextWake() time.Time {
+	var next time.Time
+	for _, state := range t.tasks {
+		if !state.wake.IsZero() && (next.IsZero() || state.wake.Before(next)) {
+			next = state.wake
+		}
+	}
+	return next
+}
+
+// wakeUp resumes the threads whose delay is over
+func (t *Tracer) wakeUp(now time.Time) error {
+	for tid, state := range t.tasks {
+		if !state.wake.IsZero() && !state.wake.After(now) {
+			state.wake = time.Time{}
+			if err := resume(tid, state.wakeSig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stopped handles a ptrace-stop, returning the signal to deliver on resume
+func (t *Tracer) stopped(ctx context.Context, tid int, state *task, wstatus syscall.WaitStatus) (int, error) {
+	switch stopSig := wstatus.StopSignal(); {
+	case !state.started && (stopSig == syscall.SIGSTOP || event(wstatus) == ptraceEventStop):
+		// initial stop of an attached thread or automatically attached child
+		state.started = true
+	case event(wstatus) == ptraceEventStop:
+	case stopSig == syscall.SIGTRAP && wstatus.TrapCause() > 0:
+		switch wstatus.TrapCause() {
+		case syscall.PTRACE_EVENT_FORK, syscall.PTRACE_EVENT_VFORK, syscall.PTRACE_EVENT_CLONE:
+			msg, err := syscall.PtraceGetEventMsg(tid)
+			if err != nil {
+				return 0, fmt.Errorf("ptrace get event msg: %w", err)
+			}
+			flags, err := cloneFlags(tid, state.regs)
+			if err != nil {
+				return 0, err
+			}
+			child, ok := t.tasks[int(msg)]
+			if !ok {
+				child = &task{}
+				t.tasks[int(msg)] = child
+			}
+			child.attached = state.attached
+			child.fds = state.fds.forked(flags)
+			child.fs = state.fs.forked(flags)
+			if child.orphan {
+				child.orphan = false
+				if err := resume(int(msg), 0); err != nil {
+					return 0, err
+				}
+			}
+		case syscall.PTRACE_EVENT_EXEC:
+			msg, err := syscall.PtraceGetEventMsg(tid)
+			if err != nil {
+				return 0, fmt.Errorf("ptrace get event msg: %w", err)
+			}
+			if former, ok := t.tasks[int(msg)]; ok && int(msg) != tid {
+				// Another thread has exec'd, and taken over the tid of the
+				// leader, which is gone, in the middle of a syscall. The
+				// execve whose exit comes next is that of the former thread.
+				state = former
+				t.tasks[tid] = state
+				delete(t.tasks, int(msg))
+				if result, ok := t.provider.results[int(msg)]; ok {
+					t.provider.results[tid] = result
+					delete(t.provider.results, int(msg))
+				}
+			}
+			state.fds = state.fds.exec()
+			t.emit(ctx, Exec{tid, int(msg)})
+		}
+	case stopSig == syscall.SIGTRAP|0x80: // syscall-stop, see PTRACE_O_TRACESYSGOOD
+		entry, r, err := syscallRegs(tid, state)
+		if err != nil {
+			return 0, err
+		}
+
+		syscallNum := r.SyscallNum
+
+		arg1 := r.Arg1
+		arg2 := r.Arg2
+		arg3 := r.Arg3
+		arg4 := r.Arg4
+		arg5 := r.Arg5
+		arg6 := r.Arg6
+
+		t.provider.pid = tid
+		t.provider.scratch = 0
+		t.provider.task = state
+		var errs []error
+		if entry {
+			state.entered = t.provider.time
+			t.emit(ctx, SyscallEnter{tid, r})
+			for _, inter := range t.interceptors {
+				r := state.regs // with the arguments changed by those before
+				errs = append(errs, inter.Before(syscallNum, r.Arg1, r.Arg2, r.Arg3, r.Arg4, r.Arg5, r.Arg6))
+			}
+		} else {
+			if result, ok := t.provider.results[tid]; ok {
+				// skipped at entry
+				delete(t.provider.results, tid)
+				if err := t.provider.SetRetVal(result); err != nil {
+					return 0, fmt.Errorf("%s: %w", syscalls.GetName(syscallNum), err)
+				}
+				r.RetVal = result
+			}
+			if err := errors.Join(trackFds(tid, state, r), trackCwd(tid, state, r)); err != nil {
+				return 0, fmt.Errorf("%s: %w", syscalls.GetName(syscallNum), err)
+			}
+			t.emit(ctx, SyscallExit{tid, r})
+			t.provider.retVal = r.RetVal
+			for _, inter := range t.interceptors {
+				// with the return value changed by those before
+				errs = append(errs, inter.After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, t.provider.retVal))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return 0, fmt.Errorf("%s: %w", syscalls.GetName(syscallNum), err)
+		}
+	default:
+		// signal-delivery-stop: pass the signal on to the tracee
+		t.emit(ctx, Signal{tid, stopSig})
+		info, err := syscalls.GetSiginfo(tid)
+		if err != nil {
+			info = syscalls.Siginfo{Signo: stopSig}
+		}
+		for _, inter := range t.interceptors {
+			if observer, ok := inter.(interceptor.Observer); ok {
+				observer.Signal(tid, info)
+			}
+		}
+		return int(stopSig), nil
+	}
+	return 0, nil
+}
+
+func (t *Tracer) emit(ctx context.Context, e Event) {
+	if t.events != nil {
+		select {
+		case t.events <- e:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (t *Tracer) log(format string, a ...any) {
+	if t.Log != nil {
+		_, _ = fmt.Fprintf(t.Log, format, a...)
+	}
+}
+
+// ErrorPolicy tells what to do when tracing a syscall fails
+type ErrorPolicy string
+
+const (
+	PolicyAbort    ErrorPolicy = "abort"    // kill started and detach attached processes
+	PolicyDetach   ErrorPolicy = "detach"   // detach all processes, leaving them running
+	PolicyContinue ErrorPolicy = "continue" // log the error and keep tracing
+)
+
+func (p *ErrorPolicy) String() string {
+	return string(*p)
+}
+
+func (p *ErrorPolicy) Set(value string) error {
+	switch policy := ErrorPolicy(value); policy {
+	case PolicyAbort, PolicyDetach, PolicyContinue:
+		*p = policy
+		return nil
+	}
+	return fmt.Errorf(`unknown policy "%s"`, value)
+}
+
+// noSyscallInfo is set when the kernel lacks PTRACE_GET_SYSCALL_INFO
+var noSyscallInfo = false
+
+// syscallRegs tells whether the thread is entering or exiting a syscall,
+// and returns the syscall number and arguments as seen at entry
+func syscallRegs(tid int, t *task) (entry bool, r syscalls.Regs, err error) {
+	op := syscalls.OpNone
+	if !noSyscallInfo {
+		info, err := syscalls.GetInfo(tid)
+		if err == syscall.EIO || err == syscall.EINVAL {
+			noSyscallInfo = true // before Linux 5.3
+		} else if err != nil {
+			return false, r, fmt.Errorf("get syscall info: %w", err)
+		}
+		op = info.Op
+		r = info.Regs
+	}
+
+	switch {
+	case op == syscalls.OpEntry, op == syscalls.OpSeccomp:
+		entry = true
+	case op == syscalls.OpExit && t.inSyscall:
+		retVal := r.RetVal
+		r = t.regs
+		r.RetVal = retVal
+	default:
+		// guess from registers, toggling between entry and exit
+		var regs syscall.PtraceRegs
+		if err := syscall.PtraceGetRegs(tid, &regs); err != nil {
+			return false, r, fmt.Errorf("get regs: %w", err)
+		}
+		mapped := syscalls.MapRegs(regs)
+		if op == syscalls.OpNone {
+			entry = !t.inSyscall
+		}
+		r = mapped
+		if !entry && t.inSyscall {
+			// argument registers may be clobbered by the return value
+			r = t.regs
+			r.RetVal = mapped.RetVal
+		}
+	}
+
+	t.inSyscall = entry
+	if entry {
+		t.regs = r
+	}
+	return entry, r, nil
+}
+
+type waitResult struct {
+	tid    int
+	status syscall.WaitStatus
+	err    error
+	time   time.Time // when wait4 returned, the nearest to the stop we know
+}
+
+// wait asks waitAll for the next state change, unless already asked, and
+// returns the channel to receive it from
+func (t *Tracer) wait() <-chan waitResult {
+	if !t.waiting {
+		t.next <- struct{}{}
+		t.waiting = true
+	}
+	return t.waits
+}
+
+// waitAll reports a state change of any child each time next asks for one,
+// until done is closed. Any thread of the tracer may wait for them, so a
+// blocking wait4 here leaves the tracing thread free to react to
+// cancellation. Waiting only when asked leaves no wait4 running after
+// tracing, which would reap other children, like the command of -o |cmd.
+func waitAll(next <-chan struct{}, results chan<- waitResult, done <-chan struct{}) {
+	for {
+		select {
+		case <-next:
+		case <-done:
+			return
+		}
+		var wstatus syscall.WaitStatus
+		tid, err := syscall.Wait4(-1, &wstatus, syscall.WALL, nil)
+		select {
+		case results <- waitResult{tid, wstatus, err, time.Now()}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// resume continues the thread until the next syscall entry or exit
+func resume(tid, sig int) error {
+	err := syscall.PtraceSyscall(tid, sig)
+	if err != nil && err != syscall.ESRCH { // ESRCH: thread is already gone
+		return fmt.Errorf("ptrace syscall (pid %d): %w", tid, err)
+	}
+	return nil
+}
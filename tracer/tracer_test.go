@@ -0,0 +1,341 @@
+package tracer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strace/interceptor"
+	"strace/syscalls"
+	"strace/tracer"
+	"strace/tracer/tracertest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunEvents(t *testing.T) {
+	tr := tracer.New()
+	events := tr.Events()
+
+	var exitGroup, exited bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			switch e := e.(type) {
+			case tracer.SyscallEnter:
+				exitGroup = exitGroup || syscalls.GetName(e.SyscallNum) == "EXIT_GROUP"
+			case tracer.Exit:
+				exited = e.Status.Exited() && e.Status.ExitStatus() == 0
+			}
+		}
+	}()
+
+	if err := tr.Start(exec.Command("true")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if !exitGroup || !exited {
+		t.Errorf("expected exit_group and exit events, got %t and %t", exitGroup, exited)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hello")
+	if err := os.WriteFile(filename, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// true, so that sh forks cat rather than exec it
+	cmd := exec.Command("sh", "-c", `cat "$0"; true`, filename)
+	tr := tracer.New()
+	tr.Follow = true
+	events := tr.Events()
+
+	tids := map[int]bool{}
+	var readTid int // of cat reading hello
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			if e, ok := e.(tracer.SyscallExit); ok {
+				tids[e.Tid] = true
+				if e.SyscallNum == syscall.SYS_READ && e.RetVal == 5 {
+					readTid = e.Tid
+				}
+			}
+		}
+	}()
+
+	if err := tr.Start(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	pid := cmd.Process.Pid
+	if !tids[pid] || readTid == 0 || readTid == pid {
+		t.Errorf("expected syscalls of sh %d and of cat, but got %v and read by %d", pid, tids, readTid)
+	}
+}
+
+func init() {
+	// the test binary as a program whose non-leader thread execs true
+	if os.Getenv("TRACER_TEST_EXEC_FROM_THREAD") != "" {
+		runtime.LockOSThread() // main keeps the leader
+		go func() {
+			path, _ := exec.LookPath("true")
+			_ = syscall.Exec(path, []string{"true"}, nil)
+			os.Exit(1)
+		}()
+		time.Sleep(time.Minute)
+		os.Exit(1)
+	}
+}
+
+func TestExecFromThread(t *testing.T) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "TRACER_TEST_EXEC_FROM_THREAD=1")
+	tr := tracer.New()
+	tr.Follow = true
+	events := tr.Events()
+
+	var execEvent *tracer.Exec
+	var exit *tracer.SyscallExit // the first of the process after exec
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			switch e := e.(type) {
+			case tracer.Exec:
+				execEvent = &e
+			case tracer.SyscallExit:
+				if execEvent != nil && exit == nil && e.Tid == execEvent.Tid {
+					exit = &e
+				}
+			}
+		}
+	}()
+
+	if err := tr.Start(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if execEvent == nil || execEvent.Tid != cmd.Process.Pid || execEvent.FormerTid == execEvent.Tid {
+		t.Fatalf("expected an exec by a thread other than %d, but got %+v", cmd.Process.Pid, execEvent)
+	}
+	if exit == nil || syscalls.GetName(exit.SyscallNum) != "EXECVE" || exit.RetVal != 0 {
+		t.Errorf("expected the exit of execve, but got %+v", exit)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	tr := tracer.New()
+	if err := tr.Start(cmd); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	defer func() {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+		_, _ = syscall.Wait4(pid, nil, 0, nil)
+	}()
+	// ends a hanging Run with the exit of sleep
+	timer := time.AfterFunc(5*time.Second, func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+	defer timer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tr.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if state, tracer := procStatus(t, pid, "State"), procStatus(t, pid, "TracerPid"); state[0] == 'T' || tracer != "0" {
+		t.Errorf("expected sleep to be detached and running, but got state %q and tracer %s", state, tracer)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	timer := time.AfterFunc(5*time.Second, func() { _ = cmd.Process.Kill() })
+	defer timer.Stop()
+
+	tr := tracer.New()
+	if err := tr.Attach(pid); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tr.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if state, tracer := procStatus(t, pid, "State"), procStatus(t, pid, "TracerPid"); state[0] != 'S' && state[0] != 'R' || tracer != "0" {
+		t.Errorf("expected sleep to be detached and running, but got state %q and tracer %s", state, tracer)
+	}
+}
+
+// procStatus returns a field of /proc/pid/status
+func procStatus(t *testing.T, pid int, key string) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, key+":"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	t.Fatalf("no %s in the status of %d", key, pid)
+	return ""
+}
+
+type testInterceptor struct {
+	before func(syscallNum int, args []int) error
+	after  func(syscallNum int, args []int, retVal int) error
+}
+
+func (i testInterceptor) Before(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6 int) error {
+	if i.before == nil {
+		return nil
+	}
+	return i.before(syscallNum, []int{arg1, arg2, arg3, arg4, arg5, arg6})
+}
+
+func (i testInterceptor) After(syscallNum, arg1, arg2, arg3, arg4, arg5, arg6, retVal int) error {
+	if i.after == nil {
+		return nil
+	}
+	return i.after(syscallNum, []int{arg1, arg2, arg3, arg4, arg5, arg6}, retVal)
+}
+
+func TestWriteMemory(t *testing.T) {
+	out := tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return testInterceptor{after: func(syscallNum int, args []int, retVal int) error {
+			if syscallNum == syscall.SYS_READ && retVal == 5 {
+				return p.WriteMemory(uintptr(args[1]), []byte("HELLO"))
+			}
+			return nil
+		}}
+	})
+	if out != "HELLO" {
+		t.Errorf(`expected "HELLO", but got %q`, out)
+	}
+}
+
+func TestSkipSyscall(t *testing.T) {
+	var retVals []int
+	out := tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return testInterceptor{
+			before: func(syscallNum int, args []int) error {
+				if syscallNum == syscall.SYS_WRITE && args[0] == 1 {
+					return p.SkipSyscall(args[2]) // pretend all is written
+				}
+				return nil
+			},
+			after: func(syscallNum int, args []int, retVal int) error {
+				if syscallNum == syscall.SYS_WRITE {
+					retVals = append(retVals, retVal)
+				}
+				return nil
+			},
+		}
+	})
+	if out != "" {
+		t.Errorf(`expected no output, but got %q`, out)
+	}
+	if len(retVals) != 1 || retVals[0] != 5 {
+		t.Errorf("expected write to return 5, but got %v", retVals)
+	}
+}
+
+func TestSetArgString(t *testing.T) {
+	other := filepath.Join(t.TempDir(), "other")
+	if err := os.WriteFile(other, []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return testInterceptor{before: func(syscallNum int, args []int) error {
+			if syscallNum == syscall.SYS_OPENAT {
+				path, err := p.ReadPtraceText(uintptr(args[1]))
+				if err == nil && filepath.Base(path) == "hello" {
+					return p.SetArgString(2, other)
+				}
+			}
+			return nil
+		}}
+	})
+	if out != "other" {
+		t.Errorf(`expected "other", but got %q`, out)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	var entered time.Time
+	var took time.Duration
+	out := tracertest.RunCat(t, func(p interceptor.Provider) interceptor.Interceptor {
+		return testInterceptor{
+			before: func(syscallNum int, args []int) error {
+				if syscallNum == syscall.SYS_WRITE {
+					entered = p.Time()
+					p.Delay(delay)
+				}
+				return nil
+			},
+			after: func(syscallNum int, args []int, retVal int) error {
+				if syscallNum == syscall.SYS_WRITE {
+					took = p.Time().Sub(entered)
+				}
+				return nil
+			},
+		}
+	})
+	if out != "hello" {
+		t.Errorf(`expected "hello", but got %q`, out)
+	}
+	if took < delay {
+		t.Errorf("expected write to take at least %v, but it took %v", delay, took)
+	}
+}
+
+func TestFileDescriptors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(filepath.Join(dir, "hello"), link); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("cat", "hello")
+	cmd.Dir = dir
+	var fds []int
+	tracertest.RunCmd(t, cmd, func(p interceptor.Provider) interceptor.Interceptor {
+		return testInterceptor{after: func(syscallNum int, args []int, retVal int) error {
+			if syscallNum == syscall.SYS_READ && retVal == 5 {
+				fds = p.FileDescriptors(link)
+			}
+			return nil
+		}}
+	})
+	if len(fds) != 1 {
+		t.Errorf("expected the fd of the file opened by another path, but got %v", fds)
+	}
+}
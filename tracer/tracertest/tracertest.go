@@ -0,0 +1,51 @@
+// Package tracertest runs commands traced with an interceptor, for tests of
+// the tracer and of interceptors.
+package tracertest
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strace/interceptor"
+	"strace/tracer"
+	"testing"
+)
+
+// RunCat traces cat of a file containing "hello", and returns its output
+func RunCat(t testing.TB, newInterceptor func(p interceptor.Provider) interceptor.Interceptor) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "hello")
+	if err := os.WriteFile(filename, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return RunCmd(t, exec.Command("cat", filename), newInterceptor)
+}
+
+// RunCmd traces the command, and returns its output
+func RunCmd(t testing.TB, cmd *exec.Cmd, newInterceptor func(p interceptor.Provider) interceptor.Interceptor) string {
+	t.Helper()
+	// a pipe, as Start waits for cmd, and cat copies between files in the kernel
+	out, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	cmd.Stdout = w
+
+	tr := tracer.New()
+	tr.Register(newInterceptor(tr.Provider()))
+	if err := tr.Start(cmd); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}